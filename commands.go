@@ -1,30 +1,98 @@
 package main
 
 import (
+  "bufio"
+  "errors"
   "fmt"
+  "io"
+  "os"
+  "strings"
 
-  "github.com/tidwall/gjson"
   "github.com/tlowerison/credential-1password/op"
   "github.com/tlowerison/credential-1password/util"
+  "golang.org/x/crypto/ssh/terminal"
 )
 
+const PassphraseEnv = "CREDENTIAL_1PASSWORD_PASSPHRASE"
+
 var ConfigKeys = []string{
+  util.AccountKey,
+  util.RouteKey,
   util.VaultKey,
 }
 
-// Get retrieves a credential from 1Password
-func Get(ctx *util.Context) error {
-  query, err := ctx.GetOpQuery()
+// readPassphrase reads the archive passphrase from $CREDENTIAL_1PASSWORD_PASSPHRASE
+// or prompts for it on the terminal, twice if confirm is true.
+func readPassphrase(confirm bool) ([]byte, error) {
+  if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+    return []byte(passphrase), nil
+  }
+
+  fd := int(os.Stdin.Fd())
+  if !terminal.IsTerminal(fd) {
+    return nil, fmt.Errorf("no terminal to read the passphrase from, set %s instead", PassphraseEnv)
+  }
+
+  fmt.Fprint(os.Stderr, "passphrase: ")
+  passphrase, err := terminal.ReadPassword(fd)
+  fmt.Fprintln(os.Stderr)
   if err != nil {
-    return err
+    return nil, err
   }
 
-  // err should only occur if document
-  // does not exist, don't want to log
-  document, err := op.GetDocument(ctx.OpFunc, *query)
-  if err == nil {
-    fmt.Println(document)
+  if confirm {
+    fmt.Fprint(os.Stderr, "confirm passphrase: ")
+    confirmation, err := terminal.ReadPassword(fd)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+      return nil, err
+    }
+    if string(confirmation) != string(passphrase) {
+      return nil, fmt.Errorf("passphrases do not match")
+    }
+  }
+  return passphrase, nil
+}
+
+func containsString(values []string, value string) bool {
+  for _, v := range values {
+    if v == value {
+      return true
+    }
   }
+  return false
+}
+
+// Get retrieves a credential from 1Password
+func Get(ctx *util.Context, args []string) error {
+  switch ctx.GetMode() {
+  case util.GoauthMode:
+    return GetGoauth(ctx, args)
+  case util.MavenMode:
+    return GetMaven(ctx)
+  case util.NetrcMode:
+    return GetNetrc(ctx)
+  case util.SSHMode:
+    return GetSSH(ctx)
+  }
+
+  if len(args) != 0 {
+    return fmt.Errorf("get only accepts a url argument in %s mode", string(util.GoauthMode))
+  }
+
+  // missing documents are expected, e.g. when git asks for a
+  // credential before prompting, though docker expects an error
+  document, err := ctx.GetDocument()
+  if errors.Is(err, op.ErrNotFound) {
+    if ctx.GetMode() == util.DockerMode {
+      return fmt.Errorf(util.ErrMsgDockerCredentialsNotFound)
+    }
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  fmt.Println(document)
   return nil
 }
 
@@ -34,7 +102,7 @@ func Op(ctx *util.Context, args []string) error {
     return err
   }
 
-  output, err := op.Op("", append([]string{"--session", sessionToken}, args...))
+  output, err := ctx.OpFunc(op.WithInteractive(ctx.GetBaseContext()), "", append([]string{"--session", sessionToken}, args...))
   if err == nil {
     fmt.Println(output)
   }
@@ -43,47 +111,373 @@ func Op(ctx *util.Context, args []string) error {
 
 // Store upserts a credential in 1Password
 func Store(ctx *util.Context) error {
-  query, err := ctx.GetOpQuery()
+  if ctx.GetMode() == util.NetrcMode {
+    return fmt.Errorf(util.ErrMsgNetrcReadOnly)
+  }
+
+  return ctx.Store()
+}
+
+// Erase removes a credential in 1Password
+func Erase(ctx *util.Context) error {
+  if ctx.GetMode() == util.NetrcMode {
+    return fmt.Errorf(util.ErrMsgNetrcReadOnly)
+  }
+
+  err := ctx.DeleteDocument()
+  if errors.Is(err, op.ErrNotFound) {
+    return nil
+  }
+  return err
+}
+
+// GetGoauth prints the Authorization headers for the provided url in
+// the format expected by the go command's GOAUTH=command protocol.
+func GetGoauth(ctx *util.Context, args []string) error {
+  rawurl := ""
+  if len(args) != 0 {
+    rawurl = args[0]
+  }
+
+  credentials, err := ctx.GetGoauth(rawurl)
+  if err != nil {
+    return err
+  }
+
+  fmt.Print(credentials)
+  return nil
+}
+
+// GetMaven prints all stored maven servers rendered as either a maven
+// settings.xml or gradle.properties, or serves the rendered output
+// through a fifo which is removed after a single read.
+func GetMaven(ctx *util.Context) error {
+  output, err := ctx.RenderMaven(ctx.Flags.Get_Format)
+  if err != nil {
+    return err
+  }
+
+  if ctx.Flags.Get_Fifo != "" {
+    return util.ServeFIFO(ctx.Flags.Get_Fifo, output)
+  }
+
+  fmt.Print(output)
+  return nil
+}
+
+// GetNetrc prints a netrc file generated from all stored git credentials,
+// or serves it through a fifo which is removed after a single read.
+func GetNetrc(ctx *util.Context) error {
+  netrc, err := ctx.GetNetrc()
+  if err != nil {
+    return err
+  }
+
+  if ctx.Flags.Get_Fifo != "" {
+    return util.ServeFIFO(ctx.Flags.Get_Fifo, netrc)
+  }
+
+  fmt.Print(netrc)
+  return nil
+}
+
+// Import reads credentials from the provided plaintext sources, or every
+// known source if none are provided, previews what will be stored and after
+// confirmation stores each one. If --scrub is provided, each successfully
+// imported credential is then removed from the source it was read from.
+func Import(ctx *util.Context, args []string) error {
+  home, err := os.UserHomeDir()
+  if err != nil {
+    return err
+  }
+
+  sources := []util.ImportSource{}
+  for _, source := range util.DefaultImportSources(home) {
+    if len(args) == 0 || containsString(args, source.Name) {
+      sources = append(sources, source)
+    }
+  }
+  for _, arg := range args {
+    if !containsString(util.ImportSources, arg) {
+      return fmt.Errorf("unknown import source %s, expected one of {%s}", arg, strings.Join(util.ImportSources, ","))
+    }
+  }
+
+  entries := []util.ImportEntry{}
+  for _, source := range sources {
+    sourceEntries, err := util.ReadImportEntries(source)
+    if err != nil {
+      return err
+    }
+    entries = append(entries, sourceEntries...)
+  }
+  if len(entries) == 0 {
+    fmt.Fprintln(os.Stderr, "no credentials found to import")
+    return nil
+  }
+
+  entries, err = ctx.PrepareImport(entries)
+  if err != nil {
+    return err
+  }
+
+  fmt.Print(util.FormatImportPreview(entries))
+  if ctx.Flags.Import_DryRun {
+    return nil
+  }
+
+  if !ctx.Flags.Import_Yes {
+    fmt.Fprintf(os.Stderr, "import %d credential(s)? [y/N] ", len(entries))
+    answer, _ := bufio.NewReader(ctx.GetStdin()).ReadString('\n')
+    if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+      return fmt.Errorf("import cancelled")
+    }
+  }
+
+  imported := []util.ImportEntry{}
+  errs := []string{}
+  for _, entry := range entries {
+    if entry.Duplicate {
+      continue
+    }
+    if err := ctx.Import(entry); err != nil {
+      errs = append(errs, fmt.Sprintf("%s from %s: %s", entry.Key, entry.Source.Path, err.Error()))
+      continue
+    }
+    imported = append(imported, entry)
+  }
+  fmt.Fprintf(os.Stderr, "imported %d credential(s)\n", len(imported))
+
+  if ctx.Flags.Import_Scrub {
+    for _, source := range sources {
+      if err := util.ScrubImportSource(source, imported); err != nil {
+        errs = append(errs, fmt.Sprintf("unable to scrub %s: %s", source.Path, err.Error()))
+      }
+    }
+  }
+
+  if len(errs) > 0 {
+    return fmt.Errorf("failed to import:\n  %s", strings.Join(errs, "\n  "))
+  }
+  return nil
+}
+
+// Doctor runs every environment check, printing each result along with how
+// to fix any failures, and returns an error if any check failed.
+func Doctor(ctx *util.Context, args []string) error {
+  failed := 0
+  for _, check := range ctx.Doctor() {
+    fmt.Println(check.String())
+    if check.Status == util.DoctorFail {
+      failed++
+    }
+  }
+
+  if failed > 0 {
+    return fmt.Errorf("%d check(s) failed", failed)
+  }
+  return nil
+}
+
+// Status prints the stored session and configured vault without signing in.
+func Status(ctx *util.Context, args []string) error {
+  status, err := ctx.Status()
+  if err != nil {
+    return err
+  }
+
+  fmt.Print(status.String())
+  return nil
+}
+
+// Logout signs out of 1Password and removes the stored session.
+func Logout(ctx *util.Context, args []string) error {
+  notified, err := ctx.Logout()
+  if notified {
+    fmt.Fprintln(os.Stderr, "removed all keys from the running ssh-agent")
+  }
+  if err != nil {
+    return err
+  }
+
+  fmt.Fprintln(os.Stderr, "signed out")
+  return nil
+}
+
+// Export writes every credential in the configured vault to an archive
+// encrypted with a passphrase, which can be read back with Restore.
+func Export(ctx *util.Context, args []string) error {
+  passphrase, err := readPassphrase(true)
+  if err != nil {
+    return err
+  }
+
+  archive, err := ctx.Export()
+  if err != nil {
+    return err
+  }
+
+  data, err := util.EncryptArchive(archive, passphrase)
+  if err != nil {
+    return err
+  }
+
+  if err := util.WriteSecretFile(args[0], string(data)); err != nil {
+    return err
+  }
+  fmt.Fprintf(os.Stderr, "exported %d credential(s) from vault %s\n", len(archive.Documents), archive.Vault)
+  return nil
+}
+
+// Restore decrypts an archive written by Export and stores each of its
+// credentials in the configured vault, replacing any with the same key.
+func Restore(ctx *util.Context, args []string) error {
+  data, err := os.ReadFile(args[0])
   if err != nil {
     return err
   }
 
-  // ignore error because missing documents are returned as errors
-  item, _ := op.GetItem(ctx.OpFunc, *query)
+  passphrase, err := readPassphrase(false)
+  if err != nil {
+    return err
+  }
 
-  uuid := gjson.Get(item, "uuid").String()
-  mode := string(ctx.GetMode())
+  archive, err := util.DecryptArchive(data, passphrase)
+  if err != nil {
+    return err
+  }
 
-  title := query.Key
-  query.Key = uuid
-  input := op.DocumentUpsert{
-    Query:    *query,
-    Content:  ctx.GetInput(),
-    FileName: fmt.Sprintf("%s-credentials", mode),
-    Title:    title,
+  if err := ctx.Restore(archive); err != nil {
+    return err
   }
+  fmt.Fprintf(os.Stderr, "restored %d credential(s) exported from vault %s\n", len(archive.Documents), archive.Vault)
+  return nil
+}
 
-  if uuid == "" {
-    _, err = op.CreateDocument(ctx.OpFunc, input)
+// Inject renders a template file (or stdin) replacing {{ cred "mode" "key" "field" }}
+// placeholders with stored credentials, and writes the output to a file only
+// readable by the current user (or stdout).
+func Inject(ctx *util.Context, args []string) error {
+  name := ctx.Flags.Inject_Input
+  var input []byte
+  var err error
+  if name == "" || name == "-" {
+    name = "stdin"
+    input, err = io.ReadAll(ctx.GetStdin())
   } else {
-    _, err = op.EditDocument(ctx.OpFunc, input)
+    input, err = os.ReadFile(name)
+  }
+  if err != nil {
+    return err
   }
 
-  return err
+  output, err := ctx.Inject(name, string(input))
+  if err != nil {
+    return err
+  }
+
+  if ctx.Flags.Inject_Output == "" || ctx.Flags.Inject_Output == "-" {
+    fmt.Print(output)
+    return nil
+  }
+  return util.WriteSecretFile(ctx.Flags.Inject_Output, output)
 }
 
-// Erase removes a credential in 1Password
-func Erase(ctx *util.Context) error {
-  query, err := ctx.GetOpQuery()
+// List prints every stored credential, or only those in the --mode if
+// provided, as a table or json. Secrets are never printed. In docker mode
+// the default output follows the docker credential helper protocol.
+func List(ctx *util.Context, args []string) error {
+  mode := ctx.GetMode()
+  if ctx.Flags.Mode != "" && mode == "" {
+    return fmt.Errorf("unknown mode %s", ctx.Flags.Mode)
+  }
+
+  entries, err := ctx.List(mode)
+  if err != nil {
+    return err
+  }
+
+  var output string
+  if mode == util.DockerMode && ctx.Flags.List_Format == "" {
+    output, err = util.FormatDockerList(entries)
+    output += "\n"
+  } else {
+    output, err = util.FormatList(entries, ctx.Flags.List_Format)
+  }
   if err != nil {
     return err
   }
-  return op.DeleteDocument(ctx.OpFunc, *query)
+
+  fmt.Print(output)
+  return nil
+}
+
+// ManifestValidate parses and validates the provided .credentials file, or the
+// nearest one if no path is provided, and prints how many credentials it lists.
+func ManifestValidate(ctx *util.Context, args []string) error {
+  path := ""
+  if len(args) > 0 {
+    path = args[0]
+  }
+
+  path, credentials, err := util.ReadCredentialsFile(path)
+  if err != nil {
+    return err
+  }
+  if path == "" {
+    return fmt.Errorf("no %s file found", util.CredentialsFileName)
+  }
+
+  fmt.Printf("%s: %d credentials\n", path, len(credentials))
+  return nil
+}
+
+// GetSSH prints the public key of the stored ssh key for the input host.
+func GetSSH(ctx *util.Context) error {
+  publicKey, err := ctx.GetSSHPublicKey()
+  if err != nil {
+    return err
+  }
+
+  fmt.Println(publicKey)
+  return nil
+}
+
+// SSHAgent loads the stored ssh keys matching the provided hosts, or
+// all stored ssh keys if no hosts are provided, into an in-memory
+// ssh agent and serves it on a unix socket until interrupted.
+func SSHAgent(ctx *util.Context, args []string) error {
+  keys, err := ctx.GetSSHKeys(args)
+  if err != nil {
+    return err
+  }
+
+  sshAgent, err := util.NewSSHAgent(keys, ctx.Flags.SSHAgent_Confirm, ctx.Flags.SSHAgent_Lifetime)
+  if err != nil {
+    return err
+  }
+
+  socketPath := ctx.Flags.SSHAgent_Socket
+  if socketPath == "" {
+    socketPath = util.DefaultSSHAgentSocket()
+  }
+
+  fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socketPath)
+  fmt.Fprintf(os.Stderr, "loaded %d ssh key(s)\n", len(keys))
+
+  // registered so that logout can remove the loaded keys
+  ctx.SetSSHAgentSocket(socketPath)
+  defer ctx.SetSSHAgentSocket("")
+  return util.ServeSSHAgent(socketPath, sshAgent)
 }
 
 func Config(ctx *util.Context, args []string) error {
   key := args[0]
   switch key {
+  case util.AccountKey:
+    return ConfigAccount(ctx, args[1:])
+  case util.RouteKey:
+    return ConfigRoute(ctx, args[1:])
   case util.VaultKey:
     return ConfigVault(ctx, args[1:])
   default:
@@ -91,8 +485,55 @@ func Config(ctx *util.Context, args []string) error {
   }
 }
 
+// ConfigAccount gets/sets the shorthand of the 1Password account used
+// when no route matches. An empty shorthand clears the default account,
+// in which case op's default account is used.
+func ConfigAccount(ctx *util.Context, args []string) error {
+  if len(args) > 1 {
+    return fmt.Errorf("config account takes at most one shorthand")
+  }
+  if len(args) == 1 {
+    return ctx.SetDefaultAccount(args[0])
+  }
+
+  account, err := ctx.GetDefaultAccount()
+  if err != nil {
+    return err
+  }
+  fmt.Println(account)
+  return nil
+}
+
+// ConfigRoute lists the configured account routes, or gets/sets the account
+// which credentials matching a route's rule are stored in. An empty
+// shorthand removes the route.
+func ConfigRoute(ctx *util.Context, args []string) error {
+  if len(args) == 2 {
+    return ctx.SetAccountRoute(args[0], args[1])
+  }
+
+  routes, err := ctx.GetAccountRoutes()
+  if err != nil {
+    return err
+  }
+  for _, route := range routes {
+    if len(args) == 0 {
+      fmt.Printf("%s=%s\n", route.Rule, route.Account)
+    } else if route.Rule == args[0] {
+      fmt.Println(route.Account)
+    }
+  }
+  return nil
+}
+
 // ConfigVault gets/sets which 1Password vault credentials should be stored in.
+// With --migrate, existing credentials are copied (or moved with --move) to
+// the new vault before it is configured.
 func ConfigVault(ctx *util.Context, args []string) error {
+  if len(args) > 1 {
+    return fmt.Errorf("config vault takes at most one vault name")
+  }
+
   vaultName, err := ctx.GetVaultName()
   if err != nil {
     return err
@@ -107,5 +548,17 @@ func ConfigVault(ctx *util.Context, args []string) error {
     }
   }
 
+  if ctx.Flags.Config_Vault_Migrate {
+    count, err := ctx.MigrateVault(args[0], ctx.Flags.Config_Vault_Create, ctx.Flags.Config_Vault_Move)
+    if err != nil {
+      return err
+    }
+    fmt.Fprintf(os.Stderr, "migrated %d credential(s) from vault %s to vault %s\n", count, vaultName, args[0])
+    return nil
+  }
+  if ctx.Flags.Config_Vault_Move {
+    return fmt.Errorf("--move can only be used with --migrate")
+  }
+
   return ctx.SetVaultName(args[0], ctx.Flags.Config_Vault_Create)
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// DockerBuild wraps `docker build` with BuildKit secrets for each credential
+// listed in the nearest .credentials file. Credentials are fetched in parallel
+// and passed to docker through files in a private temporary directory which
+// is removed once the build exits. Exits with docker's exit code.
+func DockerBuild(ctx *util.Context, args []string) error {
+  secrets, err := getDockerBuildSecrets(ctx)
+  if err != nil {
+    return err
+  }
+
+  dir, err := os.MkdirTemp("", "credential-1password-")
+  if err != nil {
+    return err
+  }
+  cleanup := closerFunc(func() error { return os.RemoveAll(dir) })
+
+  buildArgs := []string{"build"}
+  for _, id := range secrets.ids {
+    path := filepath.Join(dir, id)
+    if err := os.WriteFile(path, []byte(secrets.contents[id]), 0600); err != nil {
+      cleanup.Close()
+      return err
+    }
+    buildArgs = append(buildArgs, fmt.Sprintf("--secret=id=%s,src=%s", id, path))
+  }
+
+  cmd := exec.Command("docker", append(buildArgs, args...)...)
+  cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+  cmd.Stdin = os.Stdin
+  cmd.Stdout = os.Stdout
+  cmd.Stderr = os.Stderr
+
+  err = runAndExit(cmd, cleanup)
+  cleanup.Close()
+  return err
+}
+
+// dockerBuildSecrets are the contents of each docker build secret by id,
+// along with the order the ids were first listed in .credentials.
+type dockerBuildSecrets struct {
+  ids      []string
+  contents map[string]string
+}
+
+// getDockerBuildSecrets finds and parses the nearest .credentials file and
+// gets each listed credential. Credentials with the same secret id are
+// joined into a single secret, one document per line.
+func getDockerBuildSecrets(ctx *util.Context) (dockerBuildSecrets, error) {
+  secrets := dockerBuildSecrets{ids: []string{}, contents: map[string]string{}}
+
+  _, credentials, err := util.ReadCredentialsFile("")
+  if err != nil || len(credentials) == 0 {
+    return secrets, err
+  }
+
+  credentials, documents, err := ctx.GetCredentials(credentials)
+  if err != nil {
+    return secrets, err
+  }
+
+  for i, credential := range credentials {
+    id := credential.SecretID()
+    if _, ok := secrets.contents[id]; !ok {
+      secrets.ids = append(secrets.ids, id)
+    }
+    document := documents[i]
+    if !strings.HasSuffix(document, "\n") {
+      document += "\n"
+    }
+    secrets.contents[id] += document
+  }
+  return secrets, nil
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error { return fn() }
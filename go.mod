@@ -10,8 +10,8 @@ replace (
 
 require (
 	github.com/spf13/cobra v1.1.3
-	github.com/tidwall/gjson v1.7.4
 	github.com/tlowerison/credential-1password/keystore v0.0.0-00010101000000-000000000000
 	github.com/tlowerison/credential-1password/op v0.0.0-00010101000000-000000000000
 	github.com/tlowerison/credential-1password/util v0.0.0-00010101000000-000000000000
+	golang.org/x/crypto v0.0.0-20200323165209-0ec3e9974c59
 )
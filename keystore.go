@@ -0,0 +1,14 @@
+//go:build !e2e
+// +build !e2e
+
+package main
+
+import (
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// newKeystore returns the os keystore.
+func newKeystore() keystore.Keystore {
+  return keystore.NewKeystore(util.ServiceName)
+}
@@ -4,6 +4,6 @@ go 1.16
 
 require (
 	github.com/keybase/go-keychain v0.0.0-20201121013009-976c83ec27a6
-	github.com/tidwall/gjson v1.7.4 // indirect
-	github.com/tidwall/sjson v1.1.6 // indirect
+	github.com/tidwall/gjson v1.7.4
+	github.com/tidwall/sjson v1.1.6
 )
@@ -10,6 +10,7 @@ const ErrMultipleKeystoreItemsFound = "multiple keystore items found"
 const ErrWrongPlatform = "wrong platform"
 
 type Keystore interface {
+  Delete(key string) error
   Get(key string) (string, error)
   Set(key string, value string) error
 }
@@ -24,6 +25,17 @@ func NewKeystore(serviceName string) keystore {
   }
 }
 
+func (ks keystore) Delete(key string) error {
+  switch runtime.GOOS {
+  case "darwin":
+    return deleteOnDarwin(ks.serviceName, key)
+  case "linux":
+    return deleteOnLinux(ks.serviceName, key)
+  default:
+    return fmt.Errorf("only darwin and linux platforms are supported currently")
+  }
+}
+
 func (ks keystore) Get(key string) (string, error) {
   switch runtime.GOOS {
   case "darwin":
@@ -64,6 +76,14 @@ func NewMockKeystore(err error, items map[string]string) *mockKeystore {
   }
 }
 
+func (ks *mockKeystore) Delete(key string) error {
+  if ks.Err != nil {
+    return ks.Err
+  }
+  delete(ks.Items, key)
+  return nil
+}
+
 func (ks *mockKeystore) Get(key string) (string, error) {
   if ks.Err != nil {
     return "", ks.Err
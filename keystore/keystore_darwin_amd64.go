@@ -19,6 +19,23 @@ func getKeychainItem(serviceName string) keychain.Item {
   return item
 }
 
+// deleteOnDarwin
+func deleteOnDarwin(serviceName string, key string) error {
+  result, err := queryItem(serviceName)
+  if err != nil || result == nil {
+    return err
+  }
+
+  data, err := sjson.Delete(string(result.Data), key)
+  if err != nil {
+    return err
+  }
+
+  item := getKeychainItem(serviceName)
+  item.SetData([]byte(data))
+  return keychain.UpdateItem(item, item)
+}
+
 // getOnDarwin
 func getOnDarwin(serviceName string, key string) (string, error) {
   result, err := queryItem(serviceName)
@@ -75,5 +92,6 @@ func setOnDarwin(serviceName string, key string, value string) error {
   return keychain.UpdateItem(item, item)
 }
 
+func deleteOnLinux(serviceName string, key string) error { return fmt.Errorf(ErrWrongPlatform) }
 func getOnLinux(serviceName string, key string) (string, error) { return "", fmt.Errorf(ErrWrongPlatform) }
 func setOnLinux(serviceName string, key string, value string) error { return fmt.Errorf(ErrWrongPlatform) }
@@ -0,0 +1,73 @@
+//go:build e2e
+// +build e2e
+
+package keystore
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "sync"
+)
+
+// fileKeystore stores items unencrypted in a json file. It is only built
+// with the e2e tag, for tests which run the cli, so that release builds
+// never write session tokens to disk in plaintext.
+type fileKeystore struct {
+  mu   sync.Mutex
+  path string
+}
+
+func NewFileKeystore(path string) Keystore {
+  return &fileKeystore{path: path}
+}
+
+func (ks *fileKeystore) Delete(key string) error {
+  return ks.update(func(items map[string]string) { delete(items, key) })
+}
+
+// Get returns an empty string for missing keys, like the os keystores.
+func (ks *fileKeystore) Get(key string) (string, error) {
+  ks.mu.Lock()
+  defer ks.mu.Unlock()
+  items, err := ks.read()
+  if err != nil {
+    return "", err
+  }
+  return items[key], nil
+}
+
+func (ks *fileKeystore) Set(key string, value string) error {
+  return ks.update(func(items map[string]string) { items[key] = value })
+}
+
+func (ks *fileKeystore) read() (map[string]string, error) {
+  items := map[string]string{}
+  content, err := ioutil.ReadFile(ks.path)
+  if os.IsNotExist(err) {
+    return items, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  if err := json.Unmarshal(content, &items); err != nil {
+    return nil, fmt.Errorf("unable to read keystore file %s: %s", ks.path, err.Error())
+  }
+  return items, nil
+}
+
+func (ks *fileKeystore) update(fn func(items map[string]string)) error {
+  ks.mu.Lock()
+  defer ks.mu.Unlock()
+  items, err := ks.read()
+  if err != nil {
+    return err
+  }
+  fn(items)
+  content, err := json.Marshal(items)
+  if err != nil {
+    return err
+  }
+  return ioutil.WriteFile(ks.path, content, 0600)
+}
@@ -7,11 +7,33 @@ import (
   "github.com/keybase/go-keychain/secretservice"
 )
 
-// getAttributes
+// getAttributes returns the attributes which items are stored and searched
+// with. serviceName is deliberately left out, since existing items were
+// saved with only their name and would otherwise no longer be found.
 func getAttributes(serviceName string, key string) secretservice.Attributes {
   return map[string]string{"name": key}
 }
 
+// deleteOnLinux
+func deleteOnLinux(serviceName string, key string) error {
+  service, err := secretservice.NewService()
+  if err != nil {
+    return err
+  }
+
+  items, err := service.SearchCollection(secretservice.SecretServiceObjectPath, getAttributes(serviceName, key))
+  if err != nil {
+    return err
+  }
+
+  for _, item := range items {
+    if err := service.DeleteItem(item); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
 // getOnLinux
 func getOnLinux(serviceName string, key string) (string, error) {
   service, err := secretservice.NewService()
@@ -24,7 +46,7 @@ func getOnLinux(serviceName string, key string) (string, error) {
   }
   defer service.CloseSession(session)
 
-  items, err := service.SearchCollection(secretservice.SecretServiceObjectPath, getAttributes(key))
+  items, err := service.SearchCollection(secretservice.SecretServiceObjectPath, getAttributes(serviceName, key))
   if err != nil {
     return "", err
   } else if len(items) != 1 {
@@ -61,12 +83,13 @@ func setOnLinux(serviceName string, key string, value string) error {
 
   _, err = service.CreateItem(
     secretservice.SecretServiceObjectPath,
-    secretservice.NewSecretProperties(key, getAttributes(key)),
+    secretservice.NewSecretProperties(key, getAttributes(serviceName, key)),
     secret,
     secretservice.ReplaceBehaviorReplace,
   )
   return err
 }
 
+func deleteOnDarwin(serviceName string, key string) error { return fmt.Errorf(ErrWrongPlatform) }
 func getOnDarwin(serviceName string, key string) (string, error) { return "", fmt.Errorf(ErrWrongPlatform) }
 func setOnDarwin(serviceName string, key string, value string) error { return fmt.Errorf(ErrWrongPlatform) }
@@ -0,0 +1,25 @@
+//go:build e2e
+// +build e2e
+
+package main
+
+import (
+  "os"
+
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// keystoreFileEnv is the environment variable which, if set, stores the
+// keystore's items unencrypted in the json file at its path instead of
+// the os keystore. It is only read by builds with the e2e tag.
+const keystoreFileEnv = "CREDENTIAL_1PASSWORD_KEYSTORE_FILE"
+
+// newKeystore returns the file keystore at $CREDENTIAL_1PASSWORD_KEYSTORE_FILE
+// if set, and the os keystore otherwise.
+func newKeystore() keystore.Keystore {
+  if path := os.Getenv(keystoreFileEnv); path != "" {
+    return keystore.NewFileKeystore(path)
+  }
+  return keystore.NewKeystore(util.ServiceName)
+}
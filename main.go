@@ -4,30 +4,50 @@ import (
   "fmt"
   "os"
   "strings"
+  "time"
 
   "github.com/spf13/cobra"
-  "github.com/tlowerison/credential-1password/keystore"
   "github.com/tlowerison/credential-1password/op"
   "github.com/tlowerison/credential-1password/util"
 )
 
 func main() {
-  ctx := util.NewContext(op.Op, keystore.NewKeystore(util.ServiceName), os.Stdin)
+  ctx := util.NewContext(op.Op, newKeystore(), os.Stdin)
 
   var rootCmd *cobra.Command
   rootCmd = &cobra.Command{
     Use:   ctx.GetName(),
     Short: "credential helper for 1Password",
+    PersistentPreRun: func(_ *cobra.Command, _ []string) {
+      if ctx.Flags.Account != "" {
+        util.HandleErr(util.ValidateAccount(ctx.Flags.Account))
+      }
+      policy := op.RetryPolicy{
+        Retries:   ctx.Flags.OpRetries,
+        BaseDelay: op.DefaultRetryPolicy.BaseDelay,
+        MaxDelay:  ctx.Flags.OpRetryMaxDelay,
+      }
+      policies := op.NewRetryPolicies(policy)
+      for command, retries := range ctx.Flags.OpCommandRetries {
+        policy.Retries = retries
+        util.HandleErr(policies.Set(command, policy))
+      }
+      ctx.OpFunc = op.WithRetryPolicies(op.WithTimeout(op.Op, ctx.Flags.OpTimeout, ctx.Flags.SigninTimeout), policies)
+      if ctx.Flags.Verbose {
+        ctx.SetBaseContext(op.WithStderr(ctx.GetBaseContext(), os.Stderr))
+      }
+    },
     Run: func(cmd *cobra.Command, _ []string) {
       fmt.Println(cmd.UsageString())
     },
   }
 
   getCmd := &cobra.Command{
-    Use:    "get",
+    Use:    "get [url]",
     Short:  "get credential by key",
+    Args:   cobra.MaximumNArgs(1),
     PreRun: util.PreRunWithInput(ctx),
-    Run:   util.Run(ctx, Get),
+    Run:    util.RunWithArgs(ctx, Get),
   }
 
   storeCmd := &cobra.Command{
@@ -44,31 +64,165 @@ func main() {
     Run:    util.Run(ctx, Erase),
   }
 
+  listCmd := &cobra.Command{
+    Use:   "list",
+    Short: "list stored credentials without their secrets",
+    Args:  cobra.NoArgs,
+    Run:   util.RunWithArgs(ctx, List),
+  }
+
   opCmd := &cobra.Command{
     Use:   "op",
     Short: "execute an op command using the current session token",
     Run:   util.RunWithArgs(ctx, Op),
   }
 
+  dockerBuildCmd := &cobra.Command{
+    Use:                "docker-build [docker build args...]",
+    Short:              "run docker build with the credentials listed in .credentials as BuildKit secrets",
+    DisableFlagParsing: true,
+    Run:                util.RunWithArgs(ctx, DockerBuild),
+  }
+
+  doctorCmd := &cobra.Command{
+    Use:   "doctor",
+    Short: "check that op, the keystore, the 1Password session, the vault and git/docker are set up correctly",
+    Args:  cobra.NoArgs,
+    Run:   util.RunWithArgs(ctx, Doctor),
+  }
+
+  statusCmd := &cobra.Command{
+    Use:   "status",
+    Short: "print the signed in account, the configured vault and when the session expires",
+    Args:  cobra.NoArgs,
+    Run:   util.RunWithArgs(ctx, Status),
+  }
+
+  logoutCmd := &cobra.Command{
+    Use:   "logout",
+    Short: "sign out of 1Password, remove the session from the keystore and clear the keys of a running ssh-agent",
+    Args:  cobra.NoArgs,
+    Run:   util.RunWithArgs(ctx, Logout),
+  }
+
+  exportCmd := &cobra.Command{
+    Use:   "export file",
+    Short: fmt.Sprintf("export all credentials in the configured vault to a passphrase encrypted archive (passphrase is read from $%s or prompted for)", PassphraseEnv),
+    Args:  cobra.ExactArgs(1),
+    Run:   util.RunWithArgs(ctx, Export),
+  }
+
+  restoreCmd := &cobra.Command{
+    Use:   "restore file",
+    Short: "restore all credentials from an archive created by export into the configured vault",
+    Args:  cobra.ExactArgs(1),
+    Run:   util.RunWithArgs(ctx, Restore),
+  }
+
+  importCmd := &cobra.Command{
+    Use:   fmt.Sprintf("import [{%s}...]", strings.Join(util.ImportSources, ",")),
+    Short: "import plaintext credentials from git-credential-store, docker config, .npmrc and .netrc files",
+    Run:   util.RunWithArgs(ctx, Import),
+  }
+
+  injectCmd := &cobra.Command{
+    Use:   "inject",
+    Short: "render a template, replacing {{ cred \"mode\" \"key\" \"field\" }} placeholders with credentials",
+    Args:  cobra.NoArgs,
+    Run:   util.RunWithArgs(ctx, Inject),
+  }
+
+  runCmd := &cobra.Command{
+    Use:   "run -- command [args...]",
+    Short: "run a command with credentials injected as environment variables",
+    Args:  cobra.MinimumNArgs(1),
+    Run:   util.RunWithArgs(ctx, RunCommand),
+  }
+
+  manifestCmd := &cobra.Command{
+    Use:   "manifest",
+    Short: "work with .credentials manifests",
+    Run: func(cmd *cobra.Command, _ []string) {
+      fmt.Println(cmd.UsageString())
+    },
+  }
+
+  manifestValidateCmd := &cobra.Command{
+    Use:   "validate [path]",
+    Short: "validate a .credentials manifest (default is the nearest .credentials file)",
+    Args:  cobra.MaximumNArgs(1),
+    Run:   util.RunWithArgs(ctx, ManifestValidate),
+  }
+
+  sshAgentCmd := &cobra.Command{
+    Use:   "ssh-agent [host...]",
+    Short: "serve stored ssh keys matching the provided hosts from an in-memory ssh agent",
+    Run:   util.RunWithArgs(ctx, SSHAgent),
+  }
+
   configCmd := &cobra.Command{
     Use: "config",
     Short: fmt.Sprintf("get/set credential-1password configurations - {%s}", strings.Join(ConfigKeys, ",")),
-    Args: cobra.RangeArgs(1, 2),
+    Args: cobra.RangeArgs(1, 3),
     Run:  util.RunWithArgs(ctx, Config),
   }
 
-  rootCmd.PersistentFlags().StringVarP(&ctx.Flags.Mode, "mode", "m", "", "credential mode - predefined modes include {git,docker}; other modes can be used for basic file storage")
+  rootCmd.PersistentFlags().StringVar(&ctx.Flags.Account, "account", "", "shorthand of the 1Password account to use, overriding any configured account or route")
+  rootCmd.PersistentFlags().StringVarP(&ctx.Flags.Mode, "mode", "m", "", "credential mode - predefined modes include {git,docker,goauth,maven,netrc,ssh}; other modes can be used for basic file storage")
+  rootCmd.PersistentFlags().StringToIntVar(&ctx.Flags.OpCommandRetries, "op-command-retries", nil, "number of times to retry specific read-only op commands, overriding --op-retries, e.g. \"get document=5,list=0\"")
+  rootCmd.PersistentFlags().IntVar(&ctx.Flags.OpRetries, "op-retries", op.DefaultRetryPolicy.Retries, "number of times to retry read-only calls to op which fail with a network error or rate limit; 0 disables retries")
+  rootCmd.PersistentFlags().DurationVar(&ctx.Flags.OpRetryMaxDelay, "op-retry-max-delay", op.DefaultRetryPolicy.MaxDelay, "maximum delay between retries of calls to op, which otherwise doubles after each retry")
+  rootCmd.PersistentFlags().DurationVar(&ctx.Flags.OpTimeout, "op-timeout", time.Minute, "maximum duration of each call to op, e.g. 30s; 0 disables the timeout")
+  rootCmd.PersistentFlags().DurationVar(&ctx.Flags.SigninTimeout, "signin-timeout", 5 * time.Minute, "maximum duration of signing in and other interactive calls to op; 0 disables the timeout")
+  rootCmd.PersistentFlags().BoolVarP(&ctx.Flags.Verbose, "verbose", "v", false, "print any warnings and errors which op writes to stderr")
+
+  getCmd.Flags().StringVar(&ctx.Flags.Get_Fifo, "fifo", "", "maven and netrc modes only - serve the output through a fifo created at this path which is removed after one read")
+  getCmd.Flags().StringVar(&ctx.Flags.Get_Format, "format", "", fmt.Sprintf("maven mode only - output format {%s}", strings.Join(util.MavenFormats, ",")))
+
+  listCmd.Flags().StringVar(&ctx.Flags.List_Format, "format", "", fmt.Sprintf("output format {%s} (default is table, or the docker credential helper format in docker mode)", strings.Join(util.ListFormats, ",")))
 
   opCmd.Flags().SetInterspersed(false)
 
+  importCmd.Flags().BoolVar(&ctx.Flags.Import_DryRun, "dry-run", false, "only preview the credentials which would be imported")
+  importCmd.Flags().BoolVar(&ctx.Flags.Import_Scrub, "scrub", false, "remove each imported credential from the plaintext file it was read from")
+  importCmd.Flags().BoolVarP(&ctx.Flags.Import_Yes, "yes", "y", false, "import without asking for confirmation")
+
+  injectCmd.Flags().StringVarP(&ctx.Flags.Inject_Input, "in-file", "i", "", "template file to render (default is stdin)")
+  injectCmd.Flags().StringVarP(&ctx.Flags.Inject_Output, "out-file", "o", "", "file to write with 0600 permissions (default is stdout)")
+
+  runCmd.Flags().SetInterspersed(false)
+  runCmd.Flags().StringArrayVarP(&ctx.Flags.Run_Env, "env", "e", []string{}, "NAME=mode:key#field - set NAME to a stored credential, e.g. GITHUB_TOKEN=git:https://github.com (may be repeated)")
+  runCmd.Flags().BoolVar(&ctx.Flags.Run_Manifest, "manifest", false, "also set the env of each credential in the nearest .credentials file which has one")
+  runCmd.Flags().BoolVar(&ctx.Flags.Run_NoMasking, "no-masking", false, "do not mask credentials in the command's output")
+
+  sshAgentCmd.Flags().BoolVar(&ctx.Flags.SSHAgent_Confirm, "confirm", false, "require confirmation through $SSH_ASKPASS before each use of any key")
+  sshAgentCmd.Flags().DurationVar(&ctx.Flags.SSHAgent_Lifetime, "lifetime", 0, "maximum lifetime of keys which were stored without a lifetime, e.g. 1h")
+  sshAgentCmd.Flags().StringVar(&ctx.Flags.SSHAgent_Socket, "socket", "", "path of the agent's unix socket (default is a private directory in $TMPDIR)")
+
   configCmd.Flags().BoolVarP(&ctx.Flags.Config_Vault_Create, "create", "c", false, "If setting the vault, and no vault exists with that name, will create a new vault.")
+  configCmd.Flags().BoolVar(&ctx.Flags.Config_Vault_Migrate, "migrate", false, "If setting the vault, copy all credentials from the current vault to the new vault and verify them before switching.")
+  configCmd.Flags().BoolVar(&ctx.Flags.Config_Vault_Move, "move", false, "If migrating, delete the credentials from the previous vault once the new vault is configured.")
+
+  manifestCmd.AddCommand(manifestValidateCmd)
 
   cobra.EnableCommandSorting = false
   rootCmd.AddCommand(getCmd)
   rootCmd.AddCommand(storeCmd)
   rootCmd.AddCommand(eraseCmd)
+  rootCmd.AddCommand(listCmd)
   rootCmd.AddCommand(opCmd)
+  rootCmd.AddCommand(dockerBuildCmd)
+  rootCmd.AddCommand(exportCmd)
+  rootCmd.AddCommand(restoreCmd)
+  rootCmd.AddCommand(importCmd)
+  rootCmd.AddCommand(injectCmd)
+  rootCmd.AddCommand(runCmd)
+  rootCmd.AddCommand(manifestCmd)
+  rootCmd.AddCommand(sshAgentCmd)
   rootCmd.AddCommand(configCmd)
+  rootCmd.AddCommand(doctorCmd)
+  rootCmd.AddCommand(statusCmd)
+  rootCmd.AddCommand(logoutCmd)
 
   rootCmd.Execute()
 }
@@ -0,0 +1,75 @@
+package op
+
+import (
+  "context"
+  "sync"
+)
+
+// VaultCache caches vaults looked up with "op get vault" by both name and
+// uuid, so that resolving the same vault more than once, or a vault's uuid
+// back to its name, doesn't launch op again. Vaults are cached per session
+// token since names are only unique within an account. A nil *VaultCache
+// caches nothing.
+type VaultCache struct {
+  mu     sync.Mutex
+  vaults map[vaultCacheKey]Vault
+}
+
+type vaultCacheKey struct {
+  sessionToken string
+  key          string
+}
+
+// NewVaultCache returns an empty VaultCache.
+func NewVaultCache() *VaultCache {
+  return &VaultCache{vaults: map[vaultCacheKey]Vault{}}
+}
+
+// Add caches vault, e.g. after it was created, for the provided session token.
+func (cache *VaultCache) Add(sessionToken string, vault Vault) {
+  if cache == nil || vault.UUID == "" {
+    return
+  }
+  cache.mu.Lock()
+  defer cache.mu.Unlock()
+  cache.vaults[vaultCacheKey{sessionToken, vault.UUID}] = vault
+  if vault.Name != "" {
+    cache.vaults[vaultCacheKey{sessionToken, vault.Name}] = vault
+  }
+}
+
+// GetVault returns the cached vault with the name or uuid input.Key, or
+// otherwise wraps "op get vault" and caches the vault on success.
+func (cache *VaultCache) GetVault(ctx context.Context, op OpFunc, input Query) (Vault, error) {
+  if vault, ok := cache.get(input.SessionToken, input.Key); ok {
+    return vault, nil
+  }
+
+  vault, err := GetVault(ctx, op, input)
+  if err != nil {
+    return Vault{}, err
+  }
+  // the key may differ from the vault's name, e.g. in case
+  cache.Add(input.SessionToken, vault)
+  cache.add(input.SessionToken, input.Key, vault)
+  return vault, nil
+}
+
+func (cache *VaultCache) add(sessionToken string, key string, vault Vault) {
+  if cache == nil || key == "" {
+    return
+  }
+  cache.mu.Lock()
+  defer cache.mu.Unlock()
+  cache.vaults[vaultCacheKey{sessionToken, key}] = vault
+}
+
+func (cache *VaultCache) get(sessionToken string, key string) (Vault, bool) {
+  if cache == nil {
+    return Vault{}, false
+  }
+  cache.mu.Lock()
+  defer cache.mu.Unlock()
+  vault, ok := cache.vaults[vaultCacheKey{sessionToken, key}]
+  return vault, ok
+}
@@ -0,0 +1,129 @@
+package op
+
+import (
+  "errors"
+  "regexp"
+  "strings"
+)
+
+var (
+  ErrInvalidSession  = errors.New("invalid session token")
+  ErrMultipleMatches = errors.New("multiple items match")
+  ErrNetwork         = errors.New("network error")
+  ErrNotFound        = errors.New("item not found")
+  ErrNotSignedIn     = errors.New("not signed in")
+  ErrRateLimited     = errors.New("rate limited")
+  ErrVaultNotFound   = errors.New("vault not found")
+)
+
+// errorPrefixRegexp matches the "[ERROR] date time" prefix of op's error messages.
+var errorPrefixRegexp = regexp.MustCompile(`^\[ERROR\] (\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} )?`)
+
+// errorClasses maps the messages of both op 1.x and 2.x to sentinel errors.
+// Vault messages are matched before item messages since a vault which
+// doesn't exist is also reported while looking up an item.
+var errorClasses = []struct {
+  err     error
+  regexps []*regexp.Regexp
+}{
+  {ErrNotSignedIn, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)you are not currently signed in`),
+    regexp.MustCompile(`(?i)account is not signed in`),
+    regexp.MustCompile(`(?i)no accounts configured`),
+  }},
+  {ErrInvalidSession, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)invalid session token`),
+    regexp.MustCompile(`(?i)session expired`),
+  }},
+  {ErrRateLimited, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)too many requests`),
+    regexp.MustCompile(`\(429\)`),
+    regexp.MustCompile(`(?i)rate limit`),
+  }},
+  {ErrNetwork, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)no such host`),
+    regexp.MustCompile(`(?i)connection (refused|reset)`),
+    regexp.MustCompile(`(?i)network is unreachable`),
+    regexp.MustCompile(`(?i)(i/o|tls handshake) timeout`),
+    regexp.MustCompile(`\((500|502|503|504)\)`),
+    regexp.MustCompile(`(?i)(bad gateway|service unavailable|gateway timeout)`),
+  }},
+  {ErrMultipleMatches, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)more than one (item|document|vault) matches`),
+  }},
+  {ErrVaultNotFound, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)doesn't seem to be a vault`),
+    regexp.MustCompile(`(?i)isn't a vault`),
+    regexp.MustCompile(`(?i)no vault (found|matches)`),
+  }},
+  {ErrNotFound, []*regexp.Regexp{
+    regexp.MustCompile(`(?i)doesn't seem to be an? (item|document)`),
+    regexp.MustCompile(`(?i)isn't an? (item|document)`),
+    regexp.MustCompile(`(?i)no (item|document) (found|matches)`),
+  }},
+}
+
+// Error is an error reported by op. Err is the sentinel error which
+// the message was classified as, or nil if it was not recognized.
+type Error struct {
+  Output  string
+  Message string
+  Err     error
+}
+
+func (err *Error) Error() string {
+  return err.Output
+}
+
+func (err *Error) Unwrap() error {
+  return err.Err
+}
+
+// ParseError parses the output of a failed op command into an Error,
+// or returns nil if output has none of op's error messages. Any lines
+// preceding the error message, e.g. warnings, are skipped.
+func ParseError(output string) *Error {
+  trimmed := strings.TrimSpace(output)
+  if i := strings.Index(trimmed, "\n[ERROR]"); i != -1 && !strings.HasPrefix(trimmed, "[ERROR]") {
+    trimmed = trimmed[i+1:]
+  }
+  if !strings.HasPrefix(trimmed, "[ERROR]") {
+    return nil
+  }
+
+  message := errorPrefixRegexp.ReplaceAllString(trimmed, "")
+  opErr := &Error{Output: output, Message: message}
+  for _, class := range errorClasses {
+    for _, classRegexp := range class.regexps {
+      if classRegexp.MatchString(message) {
+        opErr.Err = class.err
+        return opErr
+      }
+    }
+  }
+  return opErr
+}
+
+// IsTransient reports whether err is a failure which may succeed if retried,
+// i.e. a network error or a rate limit.
+func IsTransient(err error) bool {
+  err = classifyError(err)
+  return errors.Is(err, ErrNetwork) || errors.Is(err, ErrRateLimited)
+}
+
+// classifyError converts errors carrying op's error messages into an
+// *Error, so that errors returned by any OpFunc can be compared against
+// the sentinel errors with errors.Is.
+func classifyError(err error) error {
+  if err == nil {
+    return nil
+  }
+  var opErr *Error
+  if errors.As(err, &opErr) {
+    return err
+  }
+  if opErr := ParseError(err.Error()); opErr != nil {
+    return opErr
+  }
+  return err
+}
@@ -1,13 +1,19 @@
 package op
 
 import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
   "fmt"
   "io"
   "os"
   "os/exec"
-  "regexp"
+  "os/signal"
   "strconv"
   "strings"
+  "syscall"
+  "time"
 )
 
 type Context struct {
@@ -34,103 +40,222 @@ type CreateVaultMutation struct {
   Title               string
 }
 
-var retryRegexps = []*regexp.Regexp{
-  regexp.MustCompile("\\[ERROR\\] \\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} You are not currently signed in. Please run `op signin --help` for instructions"),
-  regexp.MustCompile("\\[ERROR\\] \\d{4}/\\d{2}/\\d{2} \\d{2}:\\d{2}:\\d{2} Invalid session token"),
+// OpFunc runs op with the provided args and stdin, and returns its output.
+// Implementations should stop op and return an error once ctx is done.
+type OpFunc func(ctx context.Context, stdin string, args []string) (string, error)
+
+type interactiveKey struct{}
+
+// WithInteractive marks calls made with the returned context as interactive,
+// i.e. calls which may prompt the user, such as signin. Interactive calls
+// read from the terminal when no stdin is provided.
+func WithInteractive(ctx context.Context) context.Context {
+  return context.WithValue(ctx, interactiveKey{}, true)
+}
+
+// IsInteractive reports whether ctx was marked with WithInteractive.
+func IsInteractive(ctx context.Context) bool {
+  interactive, _ := ctx.Value(interactiveKey{}).(bool)
+  return interactive
 }
 
-type OpFunc func(stdin string, args []string) (string, error)
+type stderrKey struct{}
 
-// Op wraps 1Password's cli tool op.
-func Op(stdin string, args []string) (string, error) {
-  cmd := exec.Command("op", args...)
+// WithStderr returns a context whose calls to op also copy
+// everything op writes to stderr to w, e.g. for verbose logging.
+func WithStderr(ctx context.Context, w io.Writer) context.Context {
+  return context.WithValue(ctx, stderrKey{}, w)
+}
 
-  if stdin == "" {
-    cmd.Stdin = os.Stdin
-  } else {
-    stdinPipe, err := cmd.StdinPipe()
-    if err != nil {
-      return "", err
+// stderrWriter returns the writer set with WithStderr, if any.
+func stderrWriter(ctx context.Context) io.Writer {
+  w, _ := ctx.Value(stderrKey{}).(io.Writer)
+  return w
+}
+
+// WithTimeout returns an OpFunc which cancels each call to op after timeout,
+// or after interactiveTimeout for interactive calls, which usually wait on
+// the user. A timeout of 0 disables it.
+func WithTimeout(op OpFunc, timeout time.Duration, interactiveTimeout time.Duration) OpFunc {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    callTimeout := timeout
+    if IsInteractive(ctx) {
+      callTimeout = interactiveTimeout
+    }
+    if callTimeout > 0 {
+      var cancel context.CancelFunc
+      ctx, cancel = context.WithTimeout(ctx, callTimeout)
+      defer cancel()
     }
+    return op(ctx, stdin, args)
+  }
+}
+
+// Op wraps 1Password's cli tool op. Non-interactive calls run op in its own
+// process group, which is killed along with any of op's children once ctx
+// is done or the process receives SIGINT or SIGTERM.
+func Op(ctx context.Context, stdin string, args []string) (string, error) {
+  interactive := IsInteractive(ctx)
+  cmd := exec.CommandContext(ctx, "op", args...)
+  setProcessGroup(cmd, !interactive)
+
+  if stdin != "" {
+    cmd.Stdin = strings.NewReader(stdin)
+  } else if interactive {
+    cmd.Stdin = os.Stdin
+  }
+
+  // stdout is the data returned to the caller; stderr holds op's errors
+  // along with any warnings, e.g. update notices, which must not end up
+  // in a document's content
+  stdout := bytes.Buffer{}
+  stderr := bytes.Buffer{}
+  cmd.Stdout = &stdout
+  cmd.Stderr = &stderr
+  if w := stderrWriter(ctx); w != nil {
+    cmd.Stderr = io.MultiWriter(&stderr, w)
+  }
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+  defer signal.Stop(signals)
 
-    go func() {
-      defer stdinPipe.Close()
-      io.WriteString(stdinPipe, stdin)
-    }()
+  if err := cmd.Start(); err != nil {
+    return "", err
   }
 
-  outBytes, err := cmd.CombinedOutput()
-  output := string(outBytes)
+  done := make(chan struct{})
+  interrupted := make(chan os.Signal, 1)
+  go func() {
+    select {
+    case sig := <-signals:
+      interrupted <- sig
+      killProcessGroup(cmd)
+    case <-ctx.Done():
+      killProcessGroup(cmd)
+    case <-done:
+    }
+  }()
+
+  err := cmd.Wait()
+  close(done)
+
+  select {
+  case sig := <-interrupted:
+    return "", fmt.Errorf("%s interrupted by %s", commandName(args), sig)
+  default:
+  }
+  if ctxErr := ctx.Err(); ctxErr != nil {
+    if errors.Is(ctxErr, context.DeadlineExceeded) {
+      return "", fmt.Errorf("%s timed out: %w", commandName(args), ctxErr)
+    }
+    return "", fmt.Errorf("%s cancelled: %w", commandName(args), ctxErr)
+  }
 
   // err always has message "exit status 1"
-  // actual error message captured in stdout
+  // actual error message captured in stderr
   if err != nil {
-    if strings.HasPrefix(output, "[ERROR]") {
-      return "", fmt.Errorf(output)
+    if opErr := ParseError(stderr.String()); opErr != nil {
+      return "", opErr
+    }
+    if message := strings.TrimSpace(stderr.String()); message != "" {
+      return "", fmt.Errorf("%s failed: %s", commandName(args), message)
     }
     return "", err
   }
 
-  return strings.TrimSpace(string(outBytes)), nil
+  return strings.TrimSpace(stdout.String()), nil
 }
 
-// ShouldClearSessionAndRetry
-func ShouldClearSessionAndRetry(err error) bool {
-  if err == nil {
-    return false
-  }
-  for _, retryRegexp := range retryRegexps {
-    if retryRegexp.MatchString(err.Error()) {
-      return true
+// commandName returns "op" followed by args up to the first flag, e.g.
+// "op get item github", so that errors never include a session token.
+func commandName(args []string) string {
+  name := []string{"op"}
+  for _, arg := range args {
+    if strings.HasPrefix(arg, "-") {
+      break
     }
+    name = append(name, arg)
   }
-  return false
+  return strings.Join(name, " ")
+}
+
+// ShouldClearSessionAndRetry reports whether err indicates that
+// the session token is missing or expired, so that signing in
+// again and retrying may succeed.
+func ShouldClearSessionAndRetry(err error) bool {
+  err = classifyError(err)
+  return errors.Is(err, ErrNotSignedIn) || errors.Is(err, ErrInvalidSession)
+}
+
+// run calls op and classifies any error it returns.
+func (op OpFunc) run(ctx context.Context, stdin string, args []string) (string, error) {
+  output, err := op(ctx, stdin, args)
+  return output, classifyError(err)
 }
 
 // wrapped fns
 
 // CreateDocument creates a new 1Passord document
-// and returns the created login's uuid on success.
-func CreateDocument(op OpFunc, input DocumentUpsert) (string, error) {
+// and returns the created document on success.
+func CreateDocument(ctx context.Context, op OpFunc, input DocumentUpsert) (Document, error) {
   baseErrMsg := "failed to create document"
-  if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
-  if input.VaultUUID == ""    { return "", fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
-  if input.Title == ""        { return "", fmt.Errorf("%s: missing document title", baseErrMsg) }
-  if input.FileName == ""     { return "", fmt.Errorf("%s: missing document file name", baseErrMsg) }
+  if input.SessionToken == "" { return Document{}, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.VaultUUID == ""    { return Document{}, fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
+  if input.Title == ""        { return Document{}, fmt.Errorf("%s: missing document title", baseErrMsg) }
+  if input.FileName == ""     { return Document{}, fmt.Errorf("%s: missing document file name", baseErrMsg) }
 
-  return op(input.Content, []string{
+  output, err := op.run(ctx, input.Content, []string{
     "create", "document", "-",
     "--session", input.SessionToken,
     "--vault", input.VaultUUID,
     "--title", input.Title,
     "--file-name", input.FileName,
   })
+  if err != nil {
+    return Document{}, err
+  }
+
+  document := Document{}
+  if err := decodeObject("create document", output, &document); err != nil {
+    return Document{}, err
+  }
+  return document, nil
 }
 
 // CreateVault creates a new 1Passord vault and returns
-// the newly created vault's uuid on success.
-func CreateVault(op OpFunc, input CreateVaultMutation) (string, error) {
+// the newly created vault on success.
+func CreateVault(ctx context.Context, op OpFunc, input CreateVaultMutation) (Vault, error) {
   baseErrMsg := "failed to create vault"
-  if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
-  if input.Title == ""        { return "", fmt.Errorf("%s: missing title", baseErrMsg) }
-  if input.Description == ""  { return "", fmt.Errorf("%s: missing description", baseErrMsg) }
+  if input.SessionToken == "" { return Vault{}, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.Title == ""        { return Vault{}, fmt.Errorf("%s: missing title", baseErrMsg) }
+  if input.Description == ""  { return Vault{}, fmt.Errorf("%s: missing description", baseErrMsg) }
 
-  return op("", []string{
+  output, err := op.run(ctx, "", []string{
     "create", "vault", input.Title,
     "--session", input.SessionToken,
     "--description", input.Description,
     "--allow-admins-to-manage", strconv.FormatBool(input.AllowAdminsToManage),
   })
+  if err != nil {
+    return Vault{}, err
+  }
+
+  vault := Vault{}
+  if err := decodeObject("create vault", output, &vault); err != nil {
+    return Vault{}, err
+  }
+  return vault, nil
 }
 
 // DeleteDocument deletes any document by uuid, name, etc.
-func DeleteDocument(op OpFunc, input Query) error {
+func DeleteDocument(ctx context.Context, op OpFunc, input Query) error {
   baseErrMsg := "failed to delete document"
   if input.SessionToken == "" { return fmt.Errorf("%s: missing session token", baseErrMsg) }
   if input.VaultUUID == ""    { return fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
   if input.Key == ""          { return fmt.Errorf("%s: missing document title", baseErrMsg) }
 
-  _, err := op("", []string{
+  _, err := op.run(ctx, "", []string{
     "delete", "document", input.Key,
     "--session", input.SessionToken,
     "--vault", input.VaultUUID,
@@ -138,13 +263,14 @@ func DeleteDocument(op OpFunc, input Query) error {
   return err
 }
 
-// EditDocument edits a 1Passord document by uuid, name,
-// etc. and returns the edited login's uuid on success.
-func EditDocument(op OpFunc, input DocumentUpsert) (string, error) {
+// EditDocument edits a 1Passord document by uuid, name, etc. op prints
+// nothing on a successful edit, so like DeleteDocument only an error is
+// returned.
+func EditDocument(ctx context.Context, op OpFunc, input DocumentUpsert) error {
   baseErrMsg := "failed to edit document"
-  if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
-  if input.VaultUUID == ""    { return "", fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
-  if input.Key == ""          { return "", fmt.Errorf("%s: missing document title", baseErrMsg) }
+  if input.SessionToken == "" { return fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.VaultUUID == ""    { return fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
+  if input.Key == ""          { return fmt.Errorf("%s: missing document title", baseErrMsg) }
 
   args := []string{
     "edit", "document", input.Key, "-",
@@ -160,51 +286,159 @@ func EditDocument(op OpFunc, input DocumentUpsert) (string, error) {
     args = append(args, "--title", input.Title)
   }
 
-  return op(input.Content, args)
+  _, err := op.run(ctx, input.Content, args)
+  return err
 }
 
-// GetItem wraps "op get item" and captures stdout/stderr
-func GetItem(op OpFunc, input Query) (string, error) {
+// GetItem wraps "op get item" and decodes the item
+func GetItem(ctx context.Context, op OpFunc, input Query) (Item, error) {
   baseErrMsg := "failed to get item"
-  if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
-  if input.VaultUUID == ""    { return "", fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
-  if input.Key == ""          { return "", fmt.Errorf("%s: missing item title", baseErrMsg) }
+  if input.SessionToken == "" { return Item{}, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.VaultUUID == ""    { return Item{}, fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
+  if input.Key == ""          { return Item{}, fmt.Errorf("%s: missing item title", baseErrMsg) }
+
+  output, err := op.run(ctx, "", []string{
+    "get", "item", input.Key,
+    "--session", input.SessionToken,
+    "--vault", input.VaultUUID,
+  })
+  if err != nil {
+    return Item{}, err
+  }
+
+  item := Item{}
+  if err := decodeObject("get item", output, &item); err != nil {
+    return Item{}, err
+  }
+  return item, nil
+}
 
-  return op("", []string{
+// GetItemFields wraps "op get item" with --fields, so that op only outputs
+// the provided fields of the item rather than the whole item, and returns
+// each field's value by the name it was requested with. Fields which the
+// item doesn't have are left out.
+func GetItemFields(ctx context.Context, op OpFunc, input Query, fields ...string) (map[string]string, error) {
+  baseErrMsg := "failed to get item fields"
+  if input.SessionToken == "" { return nil, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.VaultUUID == ""    { return nil, fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
+  if input.Key == ""          { return nil, fmt.Errorf("%s: missing item title", baseErrMsg) }
+  if len(fields) == 0         { return nil, fmt.Errorf("%s: missing fields", baseErrMsg) }
+
+  output, err := op.run(ctx, "", []string{
     "get", "item", input.Key,
     "--session", input.SessionToken,
     "--vault", input.VaultUUID,
+    "--fields", strings.Join(fields, ","),
+    "--format", "JSON",
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  values := map[string]string{}
+  if err := json.Unmarshal([]byte(output), &values); err != nil {
+    return nil, &UnexpectedOutputError{Command: "get item", Reason: err.Error()}
+  }
+  return values, nil
+}
+
+// GetAccount wraps "op get account" and decodes the account
+func GetAccount(ctx context.Context, op OpFunc, sessionToken string) (Account, error) {
+  baseErrMsg := "failed to get account"
+  if sessionToken == "" { return Account{}, fmt.Errorf("%s: missing session token", baseErrMsg) }
+
+  output, err := op.run(ctx, "", []string{
+    "get", "account",
+    "--session", sessionToken,
   })
+  if err != nil {
+    return Account{}, err
+  }
+
+  account := Account{}
+  if err := decodeObject("get account", output, &account); err != nil {
+    return Account{}, err
+  }
+  return account, nil
 }
 
 // GetDocument wraps "op get document" and captures stdout/stderr
-func GetDocument(op OpFunc, input Query) (string, error) {
+func GetDocument(ctx context.Context, op OpFunc, input Query) (string, error) {
   baseErrMsg := "failed to get document"
   if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
   if input.VaultUUID == ""    { return "", fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
   if input.Key == ""          { return "", fmt.Errorf("%s: missing document title", baseErrMsg) }
 
-  return op("", []string{
+  return op.run(ctx, "", []string{
     "get", "document", input.Key,
     "--session", input.SessionToken,
     "--vault", input.VaultUUID,
   })
 }
 
-// GetVault wraps "op get vault" and captures stdout/stderr
-func GetVault(op OpFunc, input Query) (string, error) {
+// GetVault wraps "op get vault" and decodes the vault
+func GetVault(ctx context.Context, op OpFunc, input Query) (Vault, error) {
   baseErrMsg := "failed to get vault"
-  if input.SessionToken == "" { return "", fmt.Errorf("%s: missing session token", baseErrMsg) }
-  if input.Key == ""          { return "", fmt.Errorf("%s: missing vault name", baseErrMsg) }
+  if input.SessionToken == "" { return Vault{}, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.Key == ""          { return Vault{}, fmt.Errorf("%s: missing vault name", baseErrMsg) }
 
-  return op("", []string{
+  output, err := op.run(ctx, "", []string{
     "get", "vault", input.Key,
     "--session", input.SessionToken,
   })
+  if err != nil {
+    return Vault{}, err
+  }
+
+  vault := Vault{}
+  if err := decodeObject("get vault", output, &vault); err != nil {
+    return Vault{}, err
+  }
+  return vault, nil
+}
+
+// ListDocuments wraps "op list documents" and decodes the documents
+func ListDocuments(ctx context.Context, op OpFunc, input Context) ([]Document, error) {
+  baseErrMsg := "failed to list documents"
+  if input.SessionToken == "" { return nil, fmt.Errorf("%s: missing session token", baseErrMsg) }
+  if input.VaultUUID == ""    { return nil, fmt.Errorf("%s: missing vault uuid", baseErrMsg) }
+
+  output, err := op.run(ctx, "", []string{
+    "list", "documents",
+    "--session", input.SessionToken,
+    "--vault", input.VaultUUID,
+  })
+  if err != nil {
+    return nil, err
+  }
+
+  documents := []Document{}
+  if err := decodeArray("list documents", output, &documents); err != nil {
+    return nil, err
+  }
+  return documents, nil
+}
+
+// Signin requests the user to sign into 1Password through stdin, then
+// returns the provided session token. account is the shorthand of the
+// account to sign into, or empty to sign into the default account.
+func Signin(ctx context.Context, op OpFunc, account string) (string, error) {
+  if account == "" {
+    return op.run(WithInteractive(ctx), "", []string{"signin", "--raw"})
+  }
+  return op.run(WithInteractive(ctx), "", []string{"signin", account, "--raw"})
+}
+
+// Signout wraps "op signout", invalidating the provided session token.
+func Signout(ctx context.Context, op OpFunc, sessionToken string) error {
+  baseErrMsg := "failed to sign out"
+  if sessionToken == "" { return fmt.Errorf("%s: missing session token", baseErrMsg) }
+
+  _, err := op.run(ctx, "", []string{"signout", "--session", sessionToken})
+  return err
 }
 
-// Signin requests the user to sign into 1Password through
-// stdin, then returns the provided session token.
-func Signin(op OpFunc) (string, error) {
-  return op("", []string{"signin", "--raw"})
+// Version wraps "op --version" and returns the installed version of op.
+func Version(ctx context.Context, op OpFunc) (string, error) {
+  return op.run(ctx, "", []string{"--version"})
 }
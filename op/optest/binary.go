@@ -0,0 +1,82 @@
+package optest
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+)
+
+// StateEnv is the environment variable holding the path of the
+// json file which the fake op binary keeps its Simulator in.
+const StateEnv = "OPTEST_STATE"
+
+// binaryPackage is the package of the fake op binary.
+const binaryPackage = "github.com/tlowerison/credential-1password/op/optest/cmd/op"
+
+// Install builds the fake op binary into dir as op, so that dir can be
+// put first in PATH. It must be called from within a module which
+// requires this one, e.g. from a test.
+func Install(dir string) (string, error) {
+  path := filepath.Join(dir, "op")
+  cmd := exec.Command("go", "build", "-o", path, binaryPackage)
+  if output, err := cmd.CombinedOutput(); err != nil {
+    return "", fmt.Errorf("unable to build fake op: %s\n%s", err.Error(), string(output))
+  }
+  return path, nil
+}
+
+// Main runs the fake op binary with the provided args against the
+// Simulator saved at $OPTEST_STATE, and returns its exit code. stdin
+// is only read by commands which read a document from "-".
+func Main(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+  path := os.Getenv(StateEnv)
+  if path == "" {
+    fmt.Fprintf(stderr, "[ERROR] %s is not set\n", StateEnv)
+    return 1
+  }
+
+  // concurrent calls must not overwrite each other's changes
+  unlock, err := lockState(path)
+  if err != nil {
+    fmt.Fprintf(stderr, "[ERROR] %s\n", err.Error())
+    return 1
+  }
+  defer unlock()
+
+  sim, err := LoadSimulator(path)
+  if err != nil {
+    fmt.Fprintf(stderr, "[ERROR] %s\n", err.Error())
+    return 1
+  }
+
+  input := ""
+  for _, arg := range args {
+    if arg == "-" {
+      content, err := ioutil.ReadAll(stdin)
+      if err != nil {
+        fmt.Fprintf(stderr, "[ERROR] %s\n", err.Error())
+        return 1
+      }
+      input = string(content)
+      break
+    }
+  }
+
+  output, opErr := sim.Op(context.Background(), input, args)
+  if err := sim.Save(path); err != nil {
+    fmt.Fprintf(stderr, "[ERROR] %s\n", err.Error())
+    return 1
+  }
+  if opErr != nil {
+    fmt.Fprintln(stderr, opErr.Error())
+    return 1
+  }
+  if output != "" {
+    fmt.Fprintln(stdout, output)
+  }
+  return 0
+}
@@ -0,0 +1,13 @@
+// Command op is a fake of 1Password's cli tool op 1.x for tests, backed
+// by an optest.Simulator which is saved at $OPTEST_STATE between calls.
+package main
+
+import (
+  "os"
+
+  "github.com/tlowerison/credential-1password/op/optest"
+)
+
+func main() {
+  os.Exit(optest.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package optest
+
+// lockState is a no-op on platforms without flock, so concurrent
+// calls to the fake op binary may lose each other's changes.
+func lockState(path string) (func(), error) {
+  return func() {}, nil
+}
@@ -0,0 +1,26 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package optest
+
+import (
+  "os"
+  "syscall"
+)
+
+// lockState takes an exclusive lock on the state at path
+// and returns the function which releases it.
+func lockState(path string) (func(), error) {
+  file, err := os.OpenFile(path + ".lock", os.O_CREATE|os.O_RDWR, 0600)
+  if err != nil {
+    return nil, err
+  }
+  if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+    file.Close()
+    return nil, err
+  }
+  return func() {
+    syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+    file.Close()
+  }, nil
+}
@@ -0,0 +1,576 @@
+// Package optest provides a simulator of 1Password's cli tool op 1.x for
+// tests, which can be called as an op.OpFunc or installed on PATH as a
+// fake op binary whose state is kept in a json file between calls.
+package optest
+
+import (
+  "context"
+  "crypto/rand"
+  "encoding/base32"
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/tlowerison/credential-1password/op"
+)
+
+// DefaultAccount is the shorthand of the account which every
+// Simulator starts with, and which is signed into by default.
+const DefaultAccount = "my"
+
+// DefaultVault is the name of the vault which every account starts with.
+const DefaultVault = "Private"
+
+// Version is the version of op which the Simulator reports.
+const Version = "1.12.4"
+
+const documentTemplateUUID = "006"
+
+// Simulator is an in-memory 1Password with accounts, vaults, documents and
+// sessions which expire after SessionTimeout without use. Its exported
+// fields are its whole state, so that it can be saved and loaded as json.
+type Simulator struct {
+  Accounts       map[string]*Account `json:"accounts"`
+  DefaultAccount string              `json:"defaultAccount"`
+  Sessions       map[string]*Session `json:"sessions"`
+  SessionTimeout time.Duration       `json:"sessionTimeout"`
+
+  // Now returns the current time, and can be replaced to expire sessions.
+  Now func() time.Time `json:"-"`
+
+  mu sync.Mutex
+}
+
+// Account is a simulated 1Password account.
+type Account struct {
+  op.Account
+  Vaults []*Vault `json:"vaults"`
+}
+
+// Vault is a simulated 1Password vault.
+type Vault struct {
+  op.Vault
+  Items []*Item `json:"items"`
+}
+
+// Item is a simulated 1Password item. Documents keep their file's
+// content, which op only returns through "op get document".
+type Item struct {
+  op.Item
+  FileName string `json:"fileName,omitempty"`
+  Content  string `json:"content,omitempty"`
+}
+
+// Session is a session token's account and when it was last used.
+type Session struct {
+  Account  string    `json:"account"`
+  LastUsed time.Time `json:"lastUsed"`
+}
+
+// NewSimulator returns a Simulator with one account, DefaultAccount,
+// which has a single vault, DefaultVault.
+func NewSimulator() *Simulator {
+  sim := &Simulator{
+    Accounts:       map[string]*Account{},
+    DefaultAccount: DefaultAccount,
+    Sessions:       map[string]*Session{},
+    SessionTimeout: 30 * time.Minute,
+    Now:            time.Now,
+  }
+  sim.AddAccount(DefaultAccount)
+  return sim
+}
+
+// LoadSimulator reads a Simulator saved at path, or returns
+// a new Simulator if nothing has been saved there yet.
+func LoadSimulator(path string) (*Simulator, error) {
+  content, err := ioutil.ReadFile(path)
+  if os.IsNotExist(err) || (err == nil && len(content) == 0) {
+    return NewSimulator(), nil
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  sim := &Simulator{}
+  if err := json.Unmarshal(content, sim); err != nil {
+    return nil, fmt.Errorf("unable to read simulator state at %s: %s", path, err.Error())
+  }
+  sim.Now = time.Now
+  return sim, nil
+}
+
+// Save writes the Simulator's state to path.
+func (sim *Simulator) Save(path string) error {
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  content, err := json.MarshalIndent(sim, "", "  ")
+  if err != nil {
+    return err
+  }
+  return ioutil.WriteFile(path, content, 0600)
+}
+
+// AddAccount adds an account with the provided shorthand,
+// if it doesn't exist yet, which has a single vault, DefaultVault.
+func (sim *Simulator) AddAccount(shorthand string) *Account {
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  if account, ok := sim.Accounts[shorthand]; ok {
+    return account
+  }
+  account := &Account{Account: op.Account{
+    UUID:   newUUID(),
+    Name:   shorthand,
+    Domain: fmt.Sprintf("%s.1password.com", shorthand),
+    Type:   "I",
+  }}
+  account.addVault(DefaultVault, "")
+  sim.Accounts[shorthand] = account
+  return account
+}
+
+// ExpireSessions expires every session, as if left unused for too long.
+func (sim *Simulator) ExpireSessions() {
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  for _, session := range sim.Sessions {
+    session.LastUsed = time.Time{}
+  }
+}
+
+// Document returns the content of the document with the provided title or
+// uuid in the vault with the provided name or uuid of the provided account.
+func (sim *Simulator) Document(account string, vault string, key string) (string, bool) {
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  acct, ok := sim.Accounts[account]
+  if !ok {
+    return "", false
+  }
+  v, err := acct.vault(vault)
+  if err != nil {
+    return "", false
+  }
+  item, err := v.item(key)
+  if err != nil {
+    return "", false
+  }
+  return item.Content, true
+}
+
+// SetDocument creates or replaces the document with the provided title in
+// the vault with the provided name of the provided account, creating the
+// vault if needed.
+func (sim *Simulator) SetDocument(account string, vault string, title string, content string) {
+  acct := sim.AddAccount(account)
+
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  v, err := acct.vault(vault)
+  if err != nil {
+    v = acct.addVault(vault, "")
+  }
+  if item, err := v.item(title); err == nil {
+    item.Content = content
+    item.UpdatedAt = sim.now()
+    return
+  }
+  v.addDocument(title, fmt.Sprintf("%s.txt", title), content, sim.now())
+}
+
+// Op runs op with the provided args and stdin against the Simulator, and
+// returns its output or an error with the message op 1.x would print.
+func (sim *Simulator) Op(ctx context.Context, stdin string, args []string) (string, error) {
+  if err := ctx.Err(); err != nil {
+    return "", err
+  }
+
+  sim.mu.Lock()
+  defer sim.mu.Unlock()
+
+  output, err := sim.run(stdin, parseArgs(args))
+  if err != nil {
+    return "", fmt.Errorf("[ERROR] %s %s", sim.now().Format("2006/01/02 15:04:05"), err.Error())
+  }
+  return output, nil
+}
+
+// --- commands ---
+
+func (sim *Simulator) run(stdin string, args parsedArgs) (string, error) {
+  if _, ok := args.flags["version"]; ok {
+    return Version, nil
+  }
+
+  switch args.command(2) {
+  case "signin":
+    return sim.signin(args)
+  case "signout":
+    _, err := sim.session(args)
+    delete(sim.Sessions, args.flags["session"])
+    return "", err
+  case "get account":
+    account, err := sim.session(args)
+    if err != nil {
+      return "", err
+    }
+    return marshal(account.Account)
+  case "create vault":
+    return sim.createVault(args)
+  case "get vault":
+    vault, err := sim.vault(args, args.arg(2))
+    if err != nil {
+      return "", err
+    }
+    return marshal(vault.Vault)
+  case "list vaults":
+    account, err := sim.session(args)
+    if err != nil {
+      return "", err
+    }
+    vaults := []op.Vault{}
+    for _, vault := range account.Vaults {
+      vaults = append(vaults, vault.Vault)
+    }
+    return marshal(vaults)
+  case "create document":
+    return sim.createDocument(stdin, args)
+  case "edit document":
+    return sim.editDocument(stdin, args)
+  case "get document":
+    item, err := sim.item(args)
+    if err != nil {
+      return "", err
+    }
+    return item.Content, nil
+  case "get item":
+    item, err := sim.item(args)
+    if err != nil {
+      return "", err
+    }
+    if fields, ok := args.flags["fields"]; ok {
+      return itemFields(item, strings.Split(fields, ","), args.flags["format"])
+    }
+    return marshal(item.Item)
+  case "delete document", "delete item":
+    vault, err := sim.vault(args, args.flags["vault"])
+    if err != nil {
+      return "", err
+    }
+    item, err := vault.item(args.arg(2))
+    if err != nil {
+      return "", err
+    }
+    vault.removeItem(item.UUID)
+    return "", nil
+  case "list documents":
+    vault, err := sim.vault(args, args.flags["vault"])
+    if err != nil {
+      return "", err
+    }
+    documents := []op.Document{}
+    for _, item := range vault.Items {
+      if item.TemplateUUID == documentTemplateUUID {
+        documents = append(documents, op.Document{
+          UUID:      item.UUID,
+          VaultUUID: item.VaultUUID,
+          CreatedAt: item.CreatedAt,
+          UpdatedAt: item.UpdatedAt,
+          Overview:  item.Overview,
+        })
+      }
+    }
+    return marshal(documents)
+  default:
+    return "", fmt.Errorf("unknown command %q for \"op\"", strings.Join(args.positional, " "))
+  }
+}
+
+func (sim *Simulator) signin(args parsedArgs) (string, error) {
+  shorthand := args.arg(1)
+  if shorthand == "" {
+    shorthand = sim.DefaultAccount
+  }
+  if _, ok := sim.Accounts[shorthand]; !ok {
+    return "", fmt.Errorf("No account found for filter %q", shorthand)
+  }
+
+  token := newUUID() + newUUID()
+  sim.Sessions[token] = &Session{Account: shorthand, LastUsed: sim.now()}
+  return token, nil
+}
+
+func (sim *Simulator) createVault(args parsedArgs) (string, error) {
+  account, err := sim.session(args)
+  if err != nil {
+    return "", err
+  }
+  name := args.arg(2)
+  if name == "" {
+    return "", fmt.Errorf("a vault name is required")
+  }
+  vault := account.addVault(name, args.flags["description"])
+  return marshal(vault.Vault)
+}
+
+func (sim *Simulator) createDocument(stdin string, args parsedArgs) (string, error) {
+  vault, err := sim.vault(args, args.flags["vault"])
+  if err != nil {
+    return "", err
+  }
+  if args.arg(2) != "-" {
+    return "", fmt.Errorf("only documents read from stdin are supported")
+  }
+  title := args.flags["title"]
+  if title == "" {
+    return "", fmt.Errorf("a document title is required")
+  }
+
+  item := vault.addDocument(title, args.flags["file-name"], stdin, sim.now())
+  return marshal(op.Document{
+    UUID:      item.UUID,
+    VaultUUID: item.VaultUUID,
+    CreatedAt: item.CreatedAt,
+    UpdatedAt: item.UpdatedAt,
+    Overview:  item.Overview,
+  })
+}
+
+func (sim *Simulator) editDocument(stdin string, args parsedArgs) (string, error) {
+  item, err := sim.item(args)
+  if err != nil {
+    return "", err
+  }
+  if args.arg(3) != "-" {
+    return "", fmt.Errorf("only documents read from stdin are supported")
+  }
+
+  item.Content = stdin
+  item.UpdatedAt = sim.now()
+  if title := args.flags["title"]; title != "" {
+    item.Overview.Title = title
+  }
+  if fileName := args.flags["file-name"]; fileName != "" {
+    item.FileName = fileName
+  }
+  return "", nil
+}
+
+// item returns the item keyed by the second argument in --vault.
+func (sim *Simulator) item(args parsedArgs) (*Item, error) {
+  vault, err := sim.vault(args, args.flags["vault"])
+  if err != nil {
+    return nil, err
+  }
+  return vault.item(args.arg(2))
+}
+
+// vault returns the signed in account's vault with the provided name or uuid.
+func (sim *Simulator) vault(args parsedArgs, key string) (*Vault, error) {
+  account, err := sim.session(args)
+  if err != nil {
+    return nil, err
+  }
+  return account.vault(key)
+}
+
+// session returns the account signed into with --session,
+// and refreshes the session unless it has expired.
+func (sim *Simulator) session(args parsedArgs) (*Account, error) {
+  session, ok := sim.Sessions[args.flags["session"]]
+  if !ok {
+    return nil, fmt.Errorf("You are not currently signed in. Please run `op signin --help` for instructions")
+  }
+  if sim.SessionTimeout > 0 && sim.now().Sub(session.LastUsed) >= sim.SessionTimeout {
+    return nil, fmt.Errorf("Invalid session token")
+  }
+  session.LastUsed = sim.now()
+
+  account, ok := sim.Accounts[session.Account]
+  if !ok {
+    return nil, fmt.Errorf("Invalid session token")
+  }
+  return account, nil
+}
+
+func (sim *Simulator) now() time.Time {
+  if sim.Now == nil {
+    return time.Now()
+  }
+  return sim.Now()
+}
+
+// --- accounts, vaults and items ---
+
+func (account *Account) addVault(name string, description string) *Vault {
+  vault := &Vault{Vault: op.Vault{UUID: newUUID(), Name: name, Description: description, Type: "U"}}
+  account.Vaults = append(account.Vaults, vault)
+  return vault
+}
+
+func (account *Account) vault(key string) (*Vault, error) {
+  for _, vault := range account.Vaults {
+    if vault.UUID == key {
+      return vault, nil
+    }
+  }
+  for _, vault := range account.Vaults {
+    if strings.EqualFold(vault.Name, key) {
+      return vault, nil
+    }
+  }
+  return nil, fmt.Errorf("%q doesn't seem to be a vault in this account. Specify the vault with its UUID or name.", key)
+}
+
+func (vault *Vault) addDocument(title string, fileName string, content string, now time.Time) *Item {
+  item := &Item{
+    Item: op.Item{
+      UUID:         newUUID(),
+      TemplateUUID: documentTemplateUUID,
+      VaultUUID:    vault.UUID,
+      CreatedAt:    now,
+      UpdatedAt:    now,
+      Overview:     op.Overview{Title: title},
+    },
+    FileName: fileName,
+    Content:  content,
+  }
+  vault.Items = append(vault.Items, item)
+  return item
+}
+
+func (vault *Vault) item(key string) (*Item, error) {
+  for _, item := range vault.Items {
+    if item.UUID == key {
+      return item, nil
+    }
+  }
+
+  matches := []*Item{}
+  for _, item := range vault.Items {
+    if item.Overview.Title == key {
+      matches = append(matches, item)
+    }
+  }
+  switch len(matches) {
+  case 0:
+    return nil, fmt.Errorf("%q doesn't seem to be an item. Specify the item with its UUID, name, or domain.", key)
+  case 1:
+    return matches[0], nil
+  default:
+    uuids := []string{}
+    for _, match := range matches {
+      uuids = append(uuids, fmt.Sprintf("\n\tfor the item %q in vault %s: %s", key, vault.Name, match.UUID))
+    }
+    return nil, fmt.Errorf("More than one item matches %q. Try again and specify the item by its UUID:%s", key, strings.Join(uuids, ""))
+  }
+}
+
+// itemFields returns the requested fields of item like "op get item
+// --fields", as a json object if format is JSON or else as the value
+// of each field separated by commas.
+func itemFields(item *Item, fields []string, format string) (string, error) {
+  values := map[string]string{}
+  ordered := []string{}
+  for _, field := range fields {
+    value, ok := item.Field(field)
+    if !ok {
+      continue
+    }
+    values[field] = value
+    ordered = append(ordered, value)
+  }
+  if strings.EqualFold(format, "json") {
+    return marshal(values)
+  }
+  return strings.Join(ordered, ","), nil
+}
+
+func (vault *Vault) removeItem(uuid string) {
+  items := []*Item{}
+  for _, item := range vault.Items {
+    if item.UUID != uuid {
+      items = append(items, item)
+    }
+  }
+  vault.Items = items
+}
+
+// --- args ---
+
+// booleanFlags are the flags of op which take no value.
+var booleanFlags = map[string]bool{"raw": true, "version": true}
+
+type parsedArgs struct {
+  positional []string
+  flags      map[string]string
+}
+
+// parseArgs splits args into positional args and "--flag value" pairs.
+func parseArgs(args []string) parsedArgs {
+  parsed := parsedArgs{positional: []string{}, flags: map[string]string{}}
+  for i := 0; i < len(args); i++ {
+    arg := args[i]
+    if !strings.HasPrefix(arg, "--") {
+      parsed.positional = append(parsed.positional, arg)
+      continue
+    }
+
+    name := strings.TrimPrefix(arg, "--")
+    if j := strings.Index(name, "="); j != -1 {
+      parsed.flags[name[:j]] = name[j+1:]
+    } else if booleanFlags[name] || i == len(args)-1 {
+      parsed.flags[name] = strconv.FormatBool(true)
+    } else {
+      parsed.flags[name] = args[i+1]
+      i++
+    }
+  }
+  return parsed
+}
+
+// arg returns the positional arg at index i, or an empty string.
+func (args parsedArgs) arg(i int) string {
+  if i < len(args.positional) {
+    return args.positional[i]
+  }
+  return ""
+}
+
+// command returns up to the first n positional args, e.g. "get item".
+func (args parsedArgs) command(n int) string {
+  if args.arg(0) == "signin" || args.arg(0) == "signout" {
+    return args.arg(0)
+  }
+  if n > len(args.positional) {
+    n = len(args.positional)
+  }
+  return strings.Join(args.positional[:n], " ")
+}
+
+// --- helpers ---
+
+var uuidEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newUUID returns a random 26 character uuid like op's.
+func newUUID() string {
+  b := make([]byte, 16)
+  rand.Read(b)
+  return uuidEncoding.EncodeToString(b)
+}
+
+func marshal(v interface{}) (string, error) {
+  output, err := json.Marshal(v)
+  return string(output), err
+}
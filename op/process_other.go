@@ -0,0 +1,18 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package op
+
+import (
+  "os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups.
+func setProcessGroup(cmd *exec.Cmd, own bool) {}
+
+// killProcessGroup kills cmd's process.
+func killProcessGroup(cmd *exec.Cmd) {
+  if cmd.Process != nil {
+    cmd.Process.Kill()
+  }
+}
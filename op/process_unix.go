@@ -0,0 +1,31 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package op
+
+import (
+  "os/exec"
+  "syscall"
+)
+
+// setProcessGroup starts cmd in its own process group if own is set,
+// so that it can be killed along with its children, and so that it
+// doesn't receive the SIGINT sent to the terminal's foreground group.
+func setProcessGroup(cmd *exec.Cmd, own bool) {
+  if own {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+  }
+}
+
+// killProcessGroup kills cmd's process group if it has its own,
+// or otherwise just cmd's process.
+func killProcessGroup(cmd *exec.Cmd) {
+  if cmd.Process == nil {
+    return
+  }
+  if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+    syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+    return
+  }
+  cmd.Process.Kill()
+}
@@ -0,0 +1,128 @@
+package op
+
+import (
+  "context"
+  "fmt"
+  "math/rand"
+  "strings"
+  "time"
+)
+
+// RetryPolicy configures how WithRetry retries transient failures.
+// The delay before each retry doubles from BaseDelay up to MaxDelay,
+// and is then jittered to between half and all of itself so that
+// concurrent helpers don't retry in lockstep.
+type RetryPolicy struct {
+  Retries   int
+  BaseDelay time.Duration
+  MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy retries up to 3 times over roughly 3.5 seconds at most.
+var DefaultRetryPolicy = RetryPolicy{
+  Retries:   3,
+  BaseDelay: 500 * time.Millisecond,
+  MaxDelay:  10 * time.Second,
+}
+
+// Delay returns the delay before the provided retry, starting at 1.
+func (policy RetryPolicy) Delay(retry int) time.Duration {
+  delay := policy.BaseDelay
+  for i := 1; i < retry && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+    delay *= 2
+  }
+  if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+    delay = policy.MaxDelay
+  }
+  if delay <= 0 {
+    return 0
+  }
+  return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// RetryPolicies configures WithRetryPolicies per op command, e.g. "get"
+// or "get document". Calls are retried according to the policy of the
+// longest command prefixing their args, and not at all if there is none.
+type RetryPolicies map[string]RetryPolicy
+
+// NewRetryPolicies returns RetryPolicies which retry each of
+// readOnlyCommands according to policy.
+func NewRetryPolicies(policy RetryPolicy) RetryPolicies {
+  policies := RetryPolicies{}
+  for _, command := range readOnlyCommands {
+    policies[command] = policy
+  }
+  return policies
+}
+
+// Get returns the policy of the longest command prefixing args.
+func (policies RetryPolicies) Get(args []string) (RetryPolicy, bool) {
+  for n := len(args); n > 0; n-- {
+    if policy, ok := policies[strings.Join(args[:n], " ")]; ok {
+      return policy, true
+    }
+  }
+  return RetryPolicy{}, false
+}
+
+// Set overrides the policy of command, which must be read-only.
+func (policies RetryPolicies) Set(command string, policy RetryPolicy) error {
+  if !isReadOnly(strings.Fields(command)) {
+    return fmt.Errorf("cannot retry op command '%s', only {%s} commands are retried", command, strings.Join(readOnlyCommands, ","))
+  }
+  policies[strings.Join(strings.Fields(command), " ")] = policy
+  return nil
+}
+
+// readOnlyCommands are the op commands which WithRetry retries. Writes are
+// never retried: a 502 or 504 may come back after 1Password has already
+// committed e.g. "create document", and retrying it would create a second
+// document with the same title.
+var readOnlyCommands = []string{"get", "list", "--version"}
+
+// isReadOnly reports whether args run one of readOnlyCommands.
+func isReadOnly(args []string) bool {
+  if len(args) == 0 {
+    return false
+  }
+  for _, command := range readOnlyCommands {
+    if args[0] == command {
+      return true
+    }
+  }
+  return false
+}
+
+// WithRetry returns an OpFunc which retries read-only calls to op failing
+// with a transient error, e.g. a network error or a rate limit, according
+// to policy. Interactive calls are never retried since they would prompt
+// the user again.
+func WithRetry(op OpFunc, policy RetryPolicy) OpFunc {
+  return WithRetryPolicies(op, NewRetryPolicies(policy))
+}
+
+// WithRetryPolicies is WithRetry with a policy per command. Calls which
+// aren't read-only are never retried, whatever their policy.
+func WithRetryPolicies(op OpFunc, policies RetryPolicies) OpFunc {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    output, err := op(ctx, stdin, args)
+    err = classifyError(err)
+    policy, ok := policies.Get(args)
+    if IsInteractive(ctx) || !isReadOnly(args) || !ok {
+      return output, err
+    }
+
+    for retry := 1; retry <= policy.Retries && IsTransient(err); retry++ {
+      timer := time.NewTimer(policy.Delay(retry))
+      select {
+      case <-ctx.Done():
+        timer.Stop()
+        return "", err
+      case <-timer.C:
+      }
+      output, err = op(ctx, stdin, args)
+      err = classifyError(err)
+    }
+    return output, err
+  }
+}
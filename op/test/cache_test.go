@@ -0,0 +1,66 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op"
+)
+
+func TestVaultCache(t *testing.T) {
+  calls := 0
+  opFunc := func(ctx context.Context, stdin string, args []string) (string, error) {
+    calls++
+    if args[2] == "missing" {
+      return "", fmt.Errorf(`[ERROR] 2021/04/29 14:42:46 "missing" doesn't seem to be a vault in this account.`)
+    }
+    return fmt.Sprintf(`{"uuid":"%s-uuid","name":"Work"}`, args[4]), nil
+  }
+  query := func(sessionToken string, key string) op.Query {
+    return op.Query{Context: op.Context{SessionToken: sessionToken}, Key: key}
+  }
+
+  cache := op.NewVaultCache()
+  vault, err := cache.GetVault(context.Background(), opFunc, query("session", "work"))
+  require.Nil(t, err)
+  require.Equal(t, op.Vault{UUID: "session-uuid", Name: "Work"}, vault)
+  require.Equal(t, 1, calls)
+
+  // cached by the key it was looked up with, its name and its uuid
+  for _, key := range []string{"work", "Work", "session-uuid"} {
+    vault, err = cache.GetVault(context.Background(), opFunc, query("session", key))
+    require.Nil(t, err)
+    require.Equal(t, "session-uuid", vault.UUID)
+  }
+  require.Equal(t, 1, calls)
+
+  // per session token
+  vault, err = cache.GetVault(context.Background(), opFunc, query("other-session", "work"))
+  require.Nil(t, err)
+  require.Equal(t, "other-session-uuid", vault.UUID)
+  require.Equal(t, 2, calls)
+
+  // errors aren't cached
+  for i := 0; i < 2; i++ {
+    _, err = cache.GetVault(context.Background(), opFunc, query("session", "missing"))
+    require.True(t, errors.Is(err, op.ErrVaultNotFound))
+  }
+  require.Equal(t, 4, calls)
+
+  // created vaults can be added
+  cache.Add("session", op.Vault{UUID: "created-uuid", Name: "Created"})
+  vault, err = cache.GetVault(context.Background(), opFunc, query("session", "Created"))
+  require.Nil(t, err)
+  require.Equal(t, "created-uuid", vault.UUID)
+  require.Equal(t, 4, calls)
+
+  // a nil cache always calls op
+  var nilCache *op.VaultCache
+  nilCache.Add("session", op.Vault{UUID: "created-uuid", Name: "Created"})
+  _, err = nilCache.GetVault(context.Background(), opFunc, query("session", "work"))
+  require.Nil(t, err)
+  require.Equal(t, 5, calls)
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op"
+)
+
+func TestParseError(t *testing.T) {
+  for output, expErr := range map[string]error{
+    // op 1.x
+    "[ERROR] 2021/04/29 14:42:46 You are not currently signed in. Please run `op signin --help` for instructions": op.ErrNotSignedIn,
+    "[ERROR] 2021/04/29 14:42:46 Invalid session token":                                                           op.ErrInvalidSession,
+    `[ERROR] 2021/04/29 14:42:46 "github" doesn't seem to be an item. Specify the item with its UUID, name, or domain.`: op.ErrNotFound,
+    `[ERROR] 2021/04/29 14:42:46 "npm" doesn't seem to be a document.`:                                                  op.ErrNotFound,
+    `[ERROR] 2021/04/29 14:42:46 "work" doesn't seem to be a vault in this account. Specify the vault with its UUID or name.`: op.ErrVaultNotFound,
+    `[ERROR] 2021/04/29 14:42:46 More than one item matches "github". Try again and specify the item by its UUID:`:           op.ErrMultipleMatches,
+    "[ERROR] 2021/04/29 14:42:46 (429) Too Many Requests":                                                                 op.ErrRateLimited,
+    `[ERROR] 2021/04/29 14:42:46 Get "https://my.1password.com/api/v1/vaults": dial tcp: lookup my.1password.com: no such host`: op.ErrNetwork,
+    // op 2.x
+    "[ERROR] 2022/03/14 09:26:53 account is not signed in":                                                                    op.ErrNotSignedIn,
+    "[ERROR] 2022/03/14 09:26:53 session expired, sign in to create a new session":                                            op.ErrInvalidSession,
+    `[ERROR] 2022/03/14 09:26:53 "github" isn't an item in the "Private" vault. Specify the item with its UUID, name, or domain.`: op.ErrNotFound,
+    `[ERROR] 2022/03/14 09:26:53 "work" isn't a vault in this account. Specify the vault with its ID or name.`:                 op.ErrVaultNotFound,
+    `[ERROR] 2022/03/14 09:26:53 More than one item matches "github". Try again and specify the item by its ID:`:              op.ErrMultipleMatches,
+    "[ERROR] 2022/03/14 09:26:53 Too many requests. Try again later.":                                                         op.ErrRateLimited,
+    "[ERROR] 2022/03/14 09:26:53 (503) Service Unavailable":                                                                   op.ErrNetwork,
+  } {
+    opErr := op.ParseError(output + "\n")
+    require.NotNil(t, opErr, output)
+    require.True(t, errors.Is(opErr, expErr), output)
+    require.Equal(t, output + "\n", opErr.Error())
+  }
+
+  opErr := op.ParseError("[ERROR] 2021/04/29 14:42:46 something else went wrong")
+  require.NotNil(t, opErr)
+  require.Nil(t, opErr.Err)
+  require.Equal(t, "something else went wrong", opErr.Message)
+
+  // warnings preceding the error message are skipped
+  opErr = op.ParseError("[LOG] 2021/04/29 14:42:46 (I) a new version of op is available\n[ERROR] 2021/04/29 14:42:46 Invalid session token\n")
+  require.NotNil(t, opErr)
+  require.True(t, errors.Is(opErr, op.ErrInvalidSession))
+  require.Equal(t, "Invalid session token", opErr.Message)
+
+  require.Nil(t, op.ParseError("[LOG] 2021/04/29 14:42:46 (I) a new version of op is available"))
+  require.Nil(t, op.ParseError("exit status 1"))
+  require.Nil(t, op.ParseError(""))
+}
+
+func TestClassifiedErrors(t *testing.T) {
+  query := op.Query{Context: op.Context{SessionToken: "session-token", VaultUUID: "vault-uuid"}, Key: "key"}
+
+  // errors returned by any OpFunc are classified
+  _, err := op.GetDocument(context.Background(), testOpFuncWithErr(`[ERROR] 2021/04/29 14:42:46 "key" doesn't seem to be an item.`), query)
+  require.True(t, errors.Is(err, op.ErrNotFound))
+  require.False(t, errors.Is(err, op.ErrVaultNotFound))
+
+  err = op.DeleteDocument(context.Background(), testOpFuncWithErr(`[ERROR] 2021/04/29 14:42:46 More than one item matches "key".`), query)
+  require.True(t, errors.Is(err, op.ErrMultipleMatches))
+
+  _, err = op.GetVault(context.Background(), testOpFuncWithErr(`[ERROR] 2021/04/29 14:42:46 "key" doesn't seem to be a vault in this account.`), query)
+  require.True(t, errors.Is(err, op.ErrVaultNotFound))
+
+  // wrapped errors keep their classification
+  opErr := op.ParseError("[ERROR] 2021/04/29 14:42:46 (429) Too Many Requests")
+  _, err = op.GetItem(context.Background(), testOpFuncWithErr(""), query)
+  require.False(t, errors.Is(err, op.ErrRateLimited))
+  _, err = op.GetItem(context.Background(), func(ctx context.Context, stdin string, args []string) (string, error) {
+    return "", fmt.Errorf("get item: %w", opErr)
+  }, query)
+  require.True(t, errors.Is(err, op.ErrRateLimited))
+
+  require.True(t, op.ShouldClearSessionAndRetry(op.ParseError("[ERROR] 2022/03/14 09:26:53 account is not signed in")))
+  require.False(t, op.ShouldClearSessionAndRetry(opErr))
+}
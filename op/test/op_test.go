@@ -1,34 +1,44 @@
 package test
 
 import (
+  "context"
+  "errors"
   "fmt"
   "strings"
   "testing"
+  "time"
 
   "github.com/stretchr/testify/require"
   "github.com/tlowerison/credential-1password/op"
 )
 
 func testOpFuncWithTest(fn func(stdin string, args []string)) op.OpFunc {
-  return func(stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
     fn(stdin, args)
     return "", nil
   }
 }
 
+func testOpFuncWithTestAndOutput(output string, fn func(stdin string, args []string)) op.OpFunc {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    fn(stdin, args)
+    return output, nil
+  }
+}
+
 func testOpFuncWithOutput(output string) op.OpFunc {
-  return func(stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
     return output, nil
   }
 }
 
 func testOpFuncWithErr(errMsg string) op.OpFunc {
-  return func(stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
     return "", fmt.Errorf(errMsg)
   }
 }
 
-func testOpFunc(stdin string, args []string) (string, error) {
+func testOpFunc(ctx context.Context, stdin string, args []string) (string, error) {
   return "", nil
 }
 
@@ -48,7 +58,8 @@ func TestCreateDocument(t *testing.T) {
   content := strings.Join([]string{"foobar", "abc=123"}, "\n")
 
   output, err := op.CreateDocument(
-    testOpFuncWithTest(func(stdin string, args []string) {
+    context.Background(),
+    testOpFuncWithTestAndOutput(`{"uuid":"document-uuid","vaultUuid":"vault-uuid"}`, func(stdin string, args []string) {
       require.Equal(t, content, stdin)
       require.Equal(t, []string{"create", "document", "-", "--session", sessionToken, "--vault", vaultUUID, "--title", documentTitle, "--file-name", documentFileName}, args)
     }),
@@ -65,11 +76,12 @@ func TestCreateDocument(t *testing.T) {
     },
   )
   require.Nil(t, err)
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{UUID: "document-uuid", VaultUUID: vaultUUID}, output)
 
-  expOutput := "test-output"
+  badOutput := "test-output"
   output, err = op.CreateDocument(
-    testOpFuncWithOutput(expOutput),
+    context.Background(),
+    testOpFuncWithOutput(badOutput),
     op.DocumentUpsert{
       Query: op.Query{
         Context: op.Context{
@@ -82,12 +94,14 @@ func TestCreateDocument(t *testing.T) {
       Content: content,
     },
   )
-  require.Nil(t, err)
-  require.Equal(t, expOutput, output)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unexpected output from op")
+  require.Equal(t, op.Document{}, output)
 
 
   expErrMsg := "test-error-message"
   output, err = op.CreateDocument(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.DocumentUpsert{
       Query: op.Query{
@@ -103,9 +117,10 @@ func TestCreateDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, expErrMsg, err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{}, output)
 
   output, err = op.CreateDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -120,9 +135,10 @@ func TestCreateDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create document: missing session token", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{}, output)
 
   output, err = op.CreateDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -137,9 +153,10 @@ func TestCreateDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create document: missing vault uuid", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{}, output)
 
   output, err = op.CreateDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -154,9 +171,10 @@ func TestCreateDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create document: missing document title", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{}, output)
 
   output, err = op.CreateDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -171,7 +189,7 @@ func TestCreateDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create document: missing document file name", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Document{}, output)
 }
 
 func TestDeleteDocument(t *testing.T) {
@@ -180,6 +198,7 @@ func TestDeleteDocument(t *testing.T) {
   documentTitle := "document-title"
 
   err := op.DeleteDocument(
+    context.Background(),
     testOpFuncWithTest(func(stdin string, args []string) {
      require.Equal(t, "", stdin)
      require.Equal(t, []string{"delete", "document", documentTitle, "--session", sessionToken, "--vault", vaultUUID}, args)
@@ -196,6 +215,7 @@ func TestDeleteDocument(t *testing.T) {
 
   expErrMsg := "test-error-message"
   err = op.DeleteDocument(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.Query{
       Context: op.Context{
@@ -209,6 +229,7 @@ func TestDeleteDocument(t *testing.T) {
   require.Equal(t, expErrMsg, err.Error())
 
   err = op.DeleteDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -221,6 +242,7 @@ func TestDeleteDocument(t *testing.T) {
   require.Equal(t, "failed to delete document: missing session token", err.Error())
 
   err = op.DeleteDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -233,6 +255,7 @@ func TestDeleteDocument(t *testing.T) {
   require.Equal(t, "failed to delete document: missing vault uuid", err.Error())
 
   err = op.DeleteDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -252,7 +275,8 @@ func TestEditDocument(t *testing.T) {
   documentFileName := "document-file-name"
   content := strings.Join([]string{"foobar", "abc=123"}, "\n")
 
-  output, err := op.EditDocument(
+  err := op.EditDocument(
+    context.Background(),
     testOpFuncWithTest(func(stdin string, args []string) {
       require.Equal(t, content, stdin)
       require.Equal(t, []string{"edit", "document", documentTitle, "-", "--session", sessionToken, "--vault", vaultUUID}, args)
@@ -269,27 +293,10 @@ func TestEditDocument(t *testing.T) {
     },
   )
   require.Nil(t, err)
-  require.Equal(t, "", output)
-
-  expOutput := "test-output"
-  output, err = op.EditDocument(
-    testOpFuncWithOutput(expOutput),
-    op.DocumentUpsert{
-      Query: op.Query{
-        Context: op.Context{
-          SessionToken: sessionToken,
-          VaultUUID:    vaultUUID,
-        },
-        Key: documentTitle,
-      },
-      Content: content,
-    },
-  )
-  require.Nil(t, err)
-  require.Equal(t, expOutput, output)
 
   expErrMsg := "test-error-message"
-  output, err = op.EditDocument(
+  err = op.EditDocument(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.DocumentUpsert{
       Query: op.Query{
@@ -304,9 +311,9 @@ func TestEditDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, expErrMsg, err.Error())
-  require.Equal(t, "", output)
 
-  output, err = op.EditDocument(
+  err = op.EditDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -322,9 +329,9 @@ func TestEditDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to edit document: missing session token", err.Error())
-  require.Equal(t, "", output)
 
-  output, err = op.EditDocument(
+  err = op.EditDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -340,9 +347,9 @@ func TestEditDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to edit document: missing vault uuid", err.Error())
-  require.Equal(t, "", output)
 
-  output, err = op.EditDocument(
+  err = op.EditDocument(
+    context.Background(),
     testOpFunc,
     op.DocumentUpsert{
       Query: op.Query{
@@ -357,7 +364,6 @@ func TestEditDocument(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to edit document: missing document title", err.Error())
-  require.Equal(t, "", output)
 }
 
 func TestCreateVault(t *testing.T) {
@@ -366,7 +372,8 @@ func TestCreateVault(t *testing.T) {
   description := "a vault description"
 
   output, err := op.CreateVault(
-    testOpFuncWithTest(func(stdin string, args []string) {
+    context.Background(),
+    testOpFuncWithTestAndOutput(`{"uuid":"vault-uuid","name":"vault-title","desc":"a vault description"}`, func(stdin string, args []string) {
       require.Equal(t, []string{"create", "vault", title, "--session", sessionToken, "--description", description, "--allow-admins-to-manage", "false"}, args)
     }),
     op.CreateVaultMutation{
@@ -376,22 +383,25 @@ func TestCreateVault(t *testing.T) {
     },
   )
   require.Nil(t, err)
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{UUID: "vault-uuid", Name: title, Description: description}, output)
 
-  expOutput := "test-output"
+  badOutput := "test-output"
   output, err = op.CreateVault(
-    testOpFuncWithOutput(expOutput),
+    context.Background(),
+    testOpFuncWithOutput(badOutput),
     op.CreateVaultMutation{
       Description: description,
       SessionToken: sessionToken,
       Title: title,
     },
   )
-  require.Nil(t, err)
-  require.Equal(t, expOutput, output)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unexpected output from op")
+  require.Equal(t, op.Vault{}, output)
 
   expErrMsg := "test-error-message"
   output, err = op.CreateVault(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.CreateVaultMutation{
       Description: description,
@@ -401,9 +411,10 @@ func TestCreateVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, expErrMsg, err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 
   output, err = op.CreateVault(
+    context.Background(),
     testOpFunc,
     op.CreateVaultMutation{
       Description: description,
@@ -412,9 +423,10 @@ func TestCreateVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create vault: missing session token", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 
   output, err = op.CreateVault(
+    context.Background(),
     testOpFunc,
     op.CreateVaultMutation{
       Description: description,
@@ -423,9 +435,10 @@ func TestCreateVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create vault: missing title", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 
   output, err = op.CreateVault(
+    context.Background(),
     testOpFunc,
     op.CreateVaultMutation{
       SessionToken: sessionToken,
@@ -434,7 +447,7 @@ func TestCreateVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to create vault: missing description", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 }
 
 func TestGetDocument(t *testing.T) {
@@ -443,6 +456,7 @@ func TestGetDocument(t *testing.T) {
   documentTitle := "document-title"
 
   output, err := op.GetDocument(
+    context.Background(),
     testOpFuncWithTest(func(stdin string, args []string) {
       require.Equal(t, "", stdin)
       require.Equal(t, []string{"get", "document", documentTitle, "--session", sessionToken, "--vault", vaultUUID}, args)
@@ -460,6 +474,7 @@ func TestGetDocument(t *testing.T) {
 
   expOutput := "test-output"
   output, err = op.GetDocument(
+    context.Background(),
     testOpFuncWithOutput(expOutput),
     op.Query{
       Context: op.Context{
@@ -474,6 +489,7 @@ func TestGetDocument(t *testing.T) {
 
   expErrMsg := "test-error-message"
   output, err = op.GetDocument(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.Query{
       Context: op.Context{
@@ -488,6 +504,7 @@ func TestGetDocument(t *testing.T) {
   require.Equal(t, "", output)
 
   output, err = op.GetDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -501,6 +518,7 @@ func TestGetDocument(t *testing.T) {
   require.Equal(t, "", output)
 
   output, err = op.GetDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -514,6 +532,7 @@ func TestGetDocument(t *testing.T) {
   require.Equal(t, "", output)
 
   output, err = op.GetDocument(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -532,7 +551,8 @@ func TestGetVault(t *testing.T) {
   vaultName := "vault-name"
 
   output, err := op.GetVault(
-    testOpFuncWithTest(func(stdin string, args []string) {
+    context.Background(),
+    testOpFuncWithTestAndOutput(`{"uuid":"vault-uuid","name":"vault-name"}`, func(stdin string, args []string) {
       require.Equal(t, "", stdin)
       require.Equal(t, []string{"get", "vault", vaultName, "--session", sessionToken}, args)
     }),
@@ -544,11 +564,12 @@ func TestGetVault(t *testing.T) {
     },
   )
   require.Nil(t, err)
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{UUID: "vault-uuid", Name: vaultName}, output)
 
-  expOutput := "test-output"
+  badOutput := "test-output"
   output, err = op.GetVault(
-    testOpFuncWithOutput(expOutput),
+    context.Background(),
+    testOpFuncWithOutput(badOutput),
     op.Query{
       Context: op.Context{
         SessionToken: sessionToken,
@@ -556,11 +577,13 @@ func TestGetVault(t *testing.T) {
       Key: vaultName,
     },
   )
-  require.Nil(t, err)
-  require.Equal(t, expOutput, output)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unexpected output from op")
+  require.Equal(t, op.Vault{}, output)
 
   expErrMsg := "test-error-message"
   output, err = op.GetVault(
+    context.Background(),
     testOpFuncWithErr(expErrMsg),
     op.Query{
       Context: op.Context{
@@ -571,9 +594,10 @@ func TestGetVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, expErrMsg, err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 
   output, err = op.GetVault(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{},
@@ -582,9 +606,10 @@ func TestGetVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to get vault: missing session token", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 
   output, err = op.GetVault(
+    context.Background(),
     testOpFunc,
     op.Query{
       Context: op.Context{
@@ -594,26 +619,293 @@ func TestGetVault(t *testing.T) {
   )
   require.NotNil(t, err)
   require.Equal(t, "failed to get vault: missing vault name", err.Error())
-  require.Equal(t, "", output)
+  require.Equal(t, op.Vault{}, output)
 }
 
 func TestSignIn(t *testing.T) {
-  output, err := op.Signin(testOpFuncWithTest(func(stdin string, args []string) {
+  output, err := op.Signin(context.Background(), testOpFuncWithTest(func(stdin string, args []string) {
     require.Equal(t, "", stdin)
     require.Equal(t, []string{"signin", "--raw"}, args)
-  }))
+  }), "")
+  require.Nil(t, err)
+  require.Equal(t, "", output)
+
+  output, err = op.Signin(context.Background(), testOpFuncWithTest(func(stdin string, args []string) {
+    require.Equal(t, "", stdin)
+    require.Equal(t, []string{"signin", "my-account", "--raw"}, args)
+  }), "my-account")
   require.Nil(t, err)
   require.Equal(t, "", output)
 
   expOutput := "test-output"
-  output, err = op.Signin(testOpFuncWithOutput(expOutput))
+  output, err = op.Signin(context.Background(), testOpFuncWithOutput(expOutput), "")
   require.Nil(t, err)
   require.Equal(t, expOutput, output)
 
 
   expErrMsg := "test-error-message"
-  output, err = op.Signin(testOpFuncWithErr(expErrMsg))
+  output, err = op.Signin(context.Background(), testOpFuncWithErr(expErrMsg), "")
   require.NotNil(t, err)
   require.Equal(t, expErrMsg, err.Error())
   require.Equal(t, "", output)
 }
+
+func TestListDocuments(t *testing.T) {
+  sessionToken := "session-token"
+  vaultUUID := "vault-uuid"
+
+  output, err := op.ListDocuments(
+    context.Background(),
+    testOpFuncWithTestAndOutput(`[{"uuid":"document-uuid","overview":{"title":"document-title"}}]`, func(stdin string, args []string) {
+      require.Equal(t, "", stdin)
+      require.Equal(t, []string{"list", "documents", "--session", sessionToken, "--vault", vaultUUID}, args)
+    }),
+    op.Context{
+      SessionToken: sessionToken,
+      VaultUUID:    vaultUUID,
+    },
+  )
+  require.Nil(t, err)
+  require.Equal(t, []op.Document{{UUID: "document-uuid", Overview: op.Overview{Title: "document-title"}}}, output)
+
+  badOutput := "test-output"
+  output, err = op.ListDocuments(
+    context.Background(),
+    testOpFuncWithOutput(badOutput),
+    op.Context{
+      SessionToken: sessionToken,
+      VaultUUID:    vaultUUID,
+    },
+  )
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unexpected output from op")
+  require.Nil(t, output)
+
+  expErrMsg := "test-error-message"
+  output, err = op.ListDocuments(
+    context.Background(),
+    testOpFuncWithErr(expErrMsg),
+    op.Context{
+      SessionToken: sessionToken,
+      VaultUUID:    vaultUUID,
+    },
+  )
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+  require.Nil(t, output)
+
+  output, err = op.ListDocuments(
+    context.Background(),
+    testOpFunc,
+    op.Context{
+      VaultUUID: vaultUUID,
+    },
+  )
+  require.NotNil(t, err)
+  require.Equal(t, "failed to list documents: missing session token", err.Error())
+  require.Nil(t, output)
+
+  output, err = op.ListDocuments(
+    context.Background(),
+    testOpFunc,
+    op.Context{
+      SessionToken: sessionToken,
+    },
+  )
+  require.NotNil(t, err)
+  require.Equal(t, "failed to list documents: missing vault uuid", err.Error())
+  require.Nil(t, output)
+}
+
+func TestVersion(t *testing.T) {
+  output, err := op.Version(context.Background(), testOpFuncWithTest(func(stdin string, args []string) {
+    require.Equal(t, "", stdin)
+    require.Equal(t, []string{"--version"}, args)
+  }))
+  require.Nil(t, err)
+  require.Equal(t, "", output)
+
+  expOutput := "1.12.4"
+  output, err = op.Version(context.Background(), testOpFuncWithOutput(expOutput))
+  require.Nil(t, err)
+  require.Equal(t, expOutput, output)
+
+  expErrMsg := "test-error-message"
+  output, err = op.Version(context.Background(), testOpFuncWithErr(expErrMsg))
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+  require.Equal(t, "", output)
+}
+
+func TestGetAccount(t *testing.T) {
+  sessionToken := "session-token"
+
+  output, err := op.GetAccount(
+    context.Background(),
+    testOpFuncWithTestAndOutput(`{"uuid":"account-uuid","name":"My Account","domain":"my.1password.com"}`, func(stdin string, args []string) {
+      require.Equal(t, "", stdin)
+      require.Equal(t, []string{"get", "account", "--session", sessionToken}, args)
+    }),
+    sessionToken,
+  )
+  require.Nil(t, err)
+  require.Equal(t, op.Account{UUID: "account-uuid", Name: "My Account", Domain: "my.1password.com"}, output)
+
+  badOutput := "test-output"
+  output, err = op.GetAccount(context.Background(), testOpFuncWithOutput(badOutput), sessionToken)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unexpected output from op")
+  require.Equal(t, op.Account{}, output)
+
+  expErrMsg := "test-error-message"
+  output, err = op.GetAccount(context.Background(), testOpFuncWithErr(expErrMsg), sessionToken)
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+  require.Equal(t, op.Account{}, output)
+
+  output, err = op.GetAccount(context.Background(), testOpFunc, "")
+  require.NotNil(t, err)
+  require.Equal(t, "failed to get account: missing session token", err.Error())
+  require.Equal(t, op.Account{}, output)
+}
+
+func TestSignout(t *testing.T) {
+  sessionToken := "session-token"
+
+  err := op.Signout(
+    context.Background(),
+    testOpFuncWithTest(func(stdin string, args []string) {
+      require.Equal(t, "", stdin)
+      require.Equal(t, []string{"signout", "--session", sessionToken}, args)
+    }),
+    sessionToken,
+  )
+  require.Nil(t, err)
+
+  expErrMsg := "test-error-message"
+  err = op.Signout(context.Background(), testOpFuncWithErr(expErrMsg), sessionToken)
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+
+  err = op.Signout(context.Background(), testOpFunc, "")
+  require.NotNil(t, err)
+  require.Equal(t, "failed to sign out: missing session token", err.Error())
+}
+
+func TestGetItem(t *testing.T) {
+  sessionToken := "session-token"
+  vaultUUID := "vault-uuid"
+  itemTitle := "item-title"
+  query := op.Query{
+    Context: op.Context{
+      SessionToken: sessionToken,
+      VaultUUID:    vaultUUID,
+    },
+    Key: itemTitle,
+  }
+
+  output, err := op.GetItem(
+    context.Background(),
+    testOpFuncWithTestAndOutput(
+      `{"uuid":"item-uuid","vaultUuid":"vault-uuid","updatedAt":"2021-04-29T14:42:46Z","overview":{"title":"item-title"},"details":{"fields":[{"designation":"username","name":"username","value":"my-username"},{"designation":"password","name":"password","value":"my-password"}]}}`,
+      func(stdin string, args []string) {
+        require.Equal(t, "", stdin)
+        require.Equal(t, []string{"get", "item", itemTitle, "--session", sessionToken, "--vault", vaultUUID}, args)
+      },
+    ),
+    query,
+  )
+  require.Nil(t, err)
+  require.Equal(t, "item-uuid", output.UUID)
+  require.Equal(t, vaultUUID, output.VaultUUID)
+  require.Equal(t, itemTitle, output.Overview.Title)
+  require.Equal(t, time.Date(2021, 4, 29, 14, 42, 46, 0, time.UTC), output.UpdatedAt)
+
+  password, ok := output.Field("password")
+  require.True(t, ok)
+  require.Equal(t, "my-password", password)
+  _, ok = output.Field("otp")
+  require.False(t, ok)
+
+  expErrMsg := "test-error-message"
+  output, err = op.GetItem(context.Background(), testOpFuncWithErr(expErrMsg), query)
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+  require.Equal(t, op.Item{}, output)
+
+  output, err = op.GetItem(context.Background(), testOpFunc, op.Query{Context: query.Context})
+  require.NotNil(t, err)
+  require.Equal(t, "failed to get item: missing item title", err.Error())
+  require.Equal(t, op.Item{}, output)
+}
+
+func TestGetItemFields(t *testing.T) {
+  sessionToken := "session-token"
+  vaultUUID := "vault-uuid"
+  itemTitle := "item-title"
+  query := op.Query{
+    Context: op.Context{
+      SessionToken: sessionToken,
+      VaultUUID:    vaultUUID,
+    },
+    Key: itemTitle,
+  }
+
+  output, err := op.GetItemFields(
+    context.Background(),
+    testOpFuncWithTestAndOutput(
+      `{"password":"my-password","username":"my-username"}`,
+      func(stdin string, args []string) {
+        require.Equal(t, "", stdin)
+        require.Equal(t, []string{"get", "item", itemTitle, "--session", sessionToken, "--vault", vaultUUID, "--fields", "username,password", "--format", "JSON"}, args)
+      },
+    ),
+    query,
+    "username", "password",
+  )
+  require.Nil(t, err)
+  require.Equal(t, map[string]string{"username": "my-username", "password": "my-password"}, output)
+
+  expErrMsg := "test-error-message"
+  output, err = op.GetItemFields(context.Background(), testOpFuncWithErr(expErrMsg), query, "password")
+  require.NotNil(t, err)
+  require.Equal(t, expErrMsg, err.Error())
+  require.Nil(t, output)
+
+  _, err = op.GetItemFields(context.Background(), testOpFuncWithOutput("my-password"), query, "password")
+  require.NotNil(t, err)
+  var outputErr *op.UnexpectedOutputError
+  require.True(t, errors.As(err, &outputErr))
+
+  _, err = op.GetItemFields(context.Background(), testOpFunc, query)
+  require.NotNil(t, err)
+  require.Equal(t, "failed to get item fields: missing fields", err.Error())
+}
+
+func TestUnexpectedOutput(t *testing.T) {
+  query := op.Query{Context: op.Context{SessionToken: "session-token", VaultUUID: "vault-uuid"}, Key: "key"}
+
+  // op 2.x identifies objects by "id"
+  _, err := op.GetItem(context.Background(), testOpFuncWithOutput(`{"id":"item-id","title":"item-title"}`), query)
+  require.NotNil(t, err)
+  var outputErr *op.UnexpectedOutputError
+  require.True(t, errors.As(err, &outputErr))
+  require.Equal(t, "get item", outputErr.Command)
+  require.Equal(t, `unexpected output from op get item: found "id" instead of "uuid", only op 1.x is supported`, err.Error())
+
+  _, err = op.GetVault(context.Background(), testOpFuncWithOutput(`{"name":"vault-name"}`), query)
+  require.NotNil(t, err)
+  require.Equal(t, "unexpected output from op get vault: missing uuid", err.Error())
+
+  _, err = op.ListDocuments(context.Background(), testOpFuncWithOutput(`[{"uuid":"document-uuid"},{"overview":{}}]`), query.Context)
+  require.NotNil(t, err)
+  require.Equal(t, "unexpected output from op list documents: missing uuid", err.Error())
+
+  _, err = op.ListDocuments(context.Background(), testOpFuncWithOutput(`{"uuid":"document-uuid"}`), query.Context)
+  require.NotNil(t, err)
+  require.True(t, errors.As(err, &outputErr))
+
+  documents, err := op.ListDocuments(context.Background(), testOpFuncWithOutput(`[]`), query.Context)
+  require.Nil(t, err)
+  require.Equal(t, []op.Document{}, documents)
+}
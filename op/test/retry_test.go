@@ -0,0 +1,159 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op"
+)
+
+// testOpFuncWithFailures fails with each of errMsgs in turn before succeeding,
+// and counts how many times it was called.
+func testOpFuncWithFailures(calls *int, errMsgs ...string) op.OpFunc {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    *calls++
+    if *calls <= len(errMsgs) {
+      return "", fmt.Errorf(errMsgs[*calls-1])
+    }
+    return "output", nil
+  }
+}
+
+var testGetArgs = []string{"get", "document", "npm"}
+
+var testRetryPolicy = op.RetryPolicy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+const (
+  testRateLimitErrMsg = "[ERROR] 2021/04/29 14:42:46 (429) Too Many Requests"
+  testNetworkErrMsg   = `[ERROR] 2021/04/29 14:42:46 Get "https://my.1password.com/api/v1/account": dial tcp: lookup my.1password.com: no such host`
+)
+
+func TestIsTransient(t *testing.T) {
+  require.True(t, op.IsTransient(fmt.Errorf(testRateLimitErrMsg)))
+  require.True(t, op.IsTransient(fmt.Errorf(testNetworkErrMsg)))
+  require.True(t, op.IsTransient(fmt.Errorf("[ERROR] 2022/03/14 09:26:53 (503) Service Unavailable")))
+  require.False(t, op.IsTransient(fmt.Errorf("[ERROR] 2021/04/29 14:42:46 Invalid session token")))
+  require.False(t, op.IsTransient(fmt.Errorf("op get item timed out: %w", context.DeadlineExceeded)))
+  require.False(t, op.IsTransient(nil))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+  policy := op.RetryPolicy{Retries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+  for retry, maxDelay := range map[int]time.Duration{
+    1:  100 * time.Millisecond,
+    2:  200 * time.Millisecond,
+    3:  400 * time.Millisecond,
+    4:  800 * time.Millisecond,
+    5:  time.Second,
+    10: time.Second,
+  } {
+    for i := 0; i < 20; i++ {
+      delay := policy.Delay(retry)
+      require.GreaterOrEqual(t, int64(delay), int64(maxDelay / 2))
+      require.LessOrEqual(t, int64(delay), int64(maxDelay))
+    }
+  }
+
+  require.Equal(t, time.Duration(0), op.RetryPolicy{}.Delay(1))
+}
+
+func TestWithRetry(t *testing.T) {
+  // transient errors are retried until op succeeds
+  calls := 0
+  output, err := op.WithRetry(testOpFuncWithFailures(&calls, testRateLimitErrMsg, testNetworkErrMsg), testRetryPolicy)(context.Background(), "", testGetArgs)
+  require.Nil(t, err)
+  require.Equal(t, "output", output)
+  require.Equal(t, 3, calls)
+
+  // up to the policy's number of retries
+  calls = 0
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, testRateLimitErrMsg, testRateLimitErrMsg, testRateLimitErrMsg, testRateLimitErrMsg), testRetryPolicy)(context.Background(), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrRateLimited))
+  require.Equal(t, 4, calls)
+
+  calls = 0
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, testRateLimitErrMsg), op.RetryPolicy{})(context.Background(), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrRateLimited))
+  require.Equal(t, 1, calls)
+
+  // other errors are returned immediately
+  calls = 0
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, `[ERROR] 2021/04/29 14:42:46 "npm" doesn't seem to be a document.`), testRetryPolicy)(context.Background(), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrNotFound))
+  require.Equal(t, 1, calls)
+
+  // interactive calls are never retried
+  calls = 0
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, testNetworkErrMsg), testRetryPolicy)(op.WithInteractive(context.Background()), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrNetwork))
+  require.Equal(t, 1, calls)
+
+  // writes are never retried, since they may have been committed
+  for _, args := range [][]string{
+    {"create", "document", "-", "--title", "npm"},
+    {"create", "vault", "work"},
+    {"edit", "document", "npm"},
+    {"delete", "document", "npm"},
+  } {
+    calls = 0
+    _, err = op.WithRetry(testOpFuncWithFailures(&calls, "[ERROR] 2022/03/14 09:26:53 (502) Bad Gateway"), testRetryPolicy)(context.Background(), "", args)
+    require.True(t, errors.Is(err, op.ErrNetwork))
+    require.Equal(t, 1, calls)
+  }
+
+  calls = 0
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, testRateLimitErrMsg), testRetryPolicy)(context.Background(), "", []string{"list", "documents"})
+  require.Nil(t, err)
+  require.Equal(t, 2, calls)
+
+  // retries stop once the context is done
+  calls = 0
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  _, err = op.WithRetry(testOpFuncWithFailures(&calls, testNetworkErrMsg, testNetworkErrMsg), op.RetryPolicy{Retries: 3, BaseDelay: time.Minute})(ctx, "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrNetwork))
+  require.Equal(t, 1, calls)
+}
+
+func TestWithRetryPolicies(t *testing.T) {
+  policies := op.NewRetryPolicies(testRetryPolicy)
+  require.Nil(t, policies.Set("get  document", op.RetryPolicy{Retries: 1}))
+  require.Nil(t, policies.Set("list", op.RetryPolicy{}))
+  require.NotNil(t, policies.Set("create document", testRetryPolicy))
+  require.NotNil(t, policies.Set("signin", testRetryPolicy))
+
+  // the policy of the longest matching command applies
+  policy, ok := policies.Get(testGetArgs)
+  require.True(t, ok)
+  require.Equal(t, 1, policy.Retries)
+  policy, ok = policies.Get([]string{"get", "vault", "work"})
+  require.True(t, ok)
+  require.Equal(t, testRetryPolicy, policy)
+  _, ok = policies.Get([]string{"create", "document", "-"})
+  require.False(t, ok)
+
+  calls := 0
+  _, err := op.WithRetryPolicies(testOpFuncWithFailures(&calls, testNetworkErrMsg, testNetworkErrMsg), policies)(context.Background(), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrNetwork))
+  require.Equal(t, 2, calls)
+
+  calls = 0
+  _, err = op.WithRetryPolicies(testOpFuncWithFailures(&calls, testNetworkErrMsg), policies)(context.Background(), "", []string{"get", "vault", "work"})
+  require.Nil(t, err)
+  require.Equal(t, 2, calls)
+
+  calls = 0
+  _, err = op.WithRetryPolicies(testOpFuncWithFailures(&calls, testRateLimitErrMsg), policies)(context.Background(), "", []string{"list", "documents"})
+  require.True(t, errors.Is(err, op.ErrRateLimited))
+  require.Equal(t, 1, calls)
+
+  // commands without a policy aren't retried
+  calls = 0
+  _, err = op.WithRetryPolicies(testOpFuncWithFailures(&calls, testNetworkErrMsg), op.RetryPolicies{})(context.Background(), "", testGetArgs)
+  require.True(t, errors.Is(err, op.ErrNetwork))
+  require.Equal(t, 1, calls)
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op"
+  "github.com/tlowerison/credential-1password/op/optest"
+)
+
+func TestSimulator(t *testing.T) {
+  ctx := context.Background()
+  sim := optest.NewSimulator()
+  now := time.Now()
+  sim.Now = func() time.Time { return now }
+
+  _, err := op.GetVault(ctx, sim.Op, op.Query{Context: op.Context{SessionToken: "unknown"}, Key: optest.DefaultVault})
+  require.True(t, errors.Is(err, op.ErrNotSignedIn))
+
+  sessionToken, err := op.Signin(ctx, sim.Op, "")
+  require.Nil(t, err)
+  account, err := op.GetAccount(ctx, sim.Op, sessionToken)
+  require.Nil(t, err)
+  require.Equal(t, optest.DefaultAccount, account.Name)
+  _, err = op.Signin(ctx, sim.Op, "other")
+  require.NotNil(t, err)
+
+  version, err := op.Version(ctx, sim.Op)
+  require.Nil(t, err)
+  require.Equal(t, optest.Version, version)
+
+  // vaults
+  _, err = op.GetVault(ctx, sim.Op, op.Query{Context: op.Context{SessionToken: sessionToken}, Key: "work"})
+  require.True(t, errors.Is(err, op.ErrVaultNotFound))
+  created, err := op.CreateVault(ctx, sim.Op, op.CreateVaultMutation{SessionToken: sessionToken, Title: "Work", Description: "work"})
+  require.Nil(t, err)
+  vault, err := op.GetVault(ctx, sim.Op, op.Query{Context: op.Context{SessionToken: sessionToken}, Key: "work"})
+  require.Nil(t, err)
+  require.Equal(t, created, vault)
+
+  // documents
+  query := op.Query{Context: op.Context{SessionToken: sessionToken, VaultUUID: vault.UUID}, Key: "npm"}
+  _, err = op.GetDocument(ctx, sim.Op, query)
+  require.True(t, errors.Is(err, op.ErrNotFound))
+  document, err := op.CreateDocument(ctx, sim.Op, op.DocumentUpsert{Query: query, Title: "npm", FileName: "npm-credentials", Content: "token"})
+  require.Nil(t, err)
+  require.Equal(t, "npm", document.Overview.Title)
+
+  content, err := op.GetDocument(ctx, sim.Op, query)
+  require.Nil(t, err)
+  require.Equal(t, "token", content)
+  err = op.EditDocument(ctx, sim.Op, op.DocumentUpsert{Query: query, Content: "new-token"})
+  require.Nil(t, err)
+  content, ok := sim.Document(optest.DefaultAccount, "Work", document.UUID)
+  require.True(t, ok)
+  require.Equal(t, "new-token", content)
+
+  item, err := op.GetItem(ctx, sim.Op, query)
+  require.Nil(t, err)
+  require.Equal(t, document.UUID, item.UUID)
+  // documents have no fields
+  fields, err := op.GetItemFields(ctx, sim.Op, query, "password")
+  require.Nil(t, err)
+  require.Equal(t, map[string]string{}, fields)
+
+  _, err = op.CreateDocument(ctx, sim.Op, op.DocumentUpsert{Query: query, Title: "npm", FileName: "npm-credentials", Content: "duplicate"})
+  require.Nil(t, err)
+  _, err = op.GetDocument(ctx, sim.Op, query)
+  require.True(t, errors.Is(err, op.ErrMultipleMatches))
+  documents, err := op.ListDocuments(ctx, sim.Op, query.Context)
+  require.Nil(t, err)
+  require.Equal(t, 2, len(documents))
+
+  require.Nil(t, op.DeleteDocument(ctx, sim.Op, op.Query{Context: query.Context, Key: document.UUID}))
+  content, err = op.GetDocument(ctx, sim.Op, query)
+  require.Nil(t, err)
+  require.Equal(t, "duplicate", content)
+
+  // sessions expire once left unused
+  now = now.Add(29 * time.Minute)
+  _, err = op.GetDocument(ctx, sim.Op, query)
+  require.Nil(t, err)
+  now = now.Add(30 * time.Minute)
+  _, err = op.GetDocument(ctx, sim.Op, query)
+  require.True(t, errors.Is(err, op.ErrInvalidSession))
+  require.True(t, op.ShouldClearSessionAndRetry(err))
+
+  sessionToken, err = op.Signin(ctx, sim.Op, "")
+  require.Nil(t, err)
+  sim.ExpireSessions()
+  _, err = op.GetAccount(ctx, sim.Op, sessionToken)
+  require.True(t, errors.Is(err, op.ErrInvalidSession))
+
+  // other accounts have their own vaults
+  sim.AddAccount("other")
+  sessionToken, err = op.Signin(ctx, sim.Op, "other")
+  require.Nil(t, err)
+  _, err = op.GetVault(ctx, sim.Op, op.Query{Context: op.Context{SessionToken: sessionToken}, Key: "work"})
+  require.True(t, errors.Is(err, op.ErrVaultNotFound))
+  require.Nil(t, op.Signout(ctx, sim.Op, sessionToken))
+  _, err = op.GetAccount(ctx, sim.Op, sessionToken)
+  require.True(t, errors.Is(err, op.ErrNotSignedIn))
+}
+
+func TestSimulatorBinary(t *testing.T) {
+  if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+    t.Skip("the fake op binary requires flock")
+  }
+
+  dir := t.TempDir()
+  _, err := optest.Install(dir)
+  require.Nil(t, err)
+  path := os.Getenv("PATH")
+  os.Setenv("PATH", dir + string(os.PathListSeparator) + path)
+  defer os.Setenv("PATH", path)
+
+  statePath := filepath.Join(dir, "state.json")
+  sim := optest.NewSimulator()
+  sim.SetDocument(optest.DefaultAccount, optest.DefaultVault, "npm", "token")
+  require.Nil(t, sim.Save(statePath))
+  os.Setenv(optest.StateEnv, statePath)
+  defer os.Setenv(optest.StateEnv, "")
+
+  // state is kept between calls
+  ctx := context.Background()
+  sessionToken, err := op.Signin(ctx, op.Op, "")
+  require.Nil(t, err)
+  vault, err := op.GetVault(ctx, op.Op, op.Query{Context: op.Context{SessionToken: sessionToken}, Key: optest.DefaultVault})
+  require.Nil(t, err)
+  query := op.Query{Context: op.Context{SessionToken: sessionToken, VaultUUID: vault.UUID}, Key: "npm"}
+  content, err := op.GetDocument(ctx, op.Op, query)
+  require.Nil(t, err)
+  require.Equal(t, "token", content)
+
+  err = op.EditDocument(ctx, op.Op, op.DocumentUpsert{Query: query, Content: "new-token"})
+  require.Nil(t, err)
+  _, err = op.GetDocument(ctx, op.Op, op.Query{Context: query.Context, Key: "missing"})
+  require.True(t, errors.Is(err, op.ErrNotFound))
+
+  sim, err = optest.LoadSimulator(statePath)
+  require.Nil(t, err)
+  content, ok := sim.Document(optest.DefaultAccount, optest.DefaultVault, "npm")
+  require.True(t, ok)
+  require.Equal(t, "new-token", content)
+}
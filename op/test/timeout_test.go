@@ -0,0 +1,126 @@
+package test
+
+import (
+  "bytes"
+  "context"
+  "errors"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "runtime"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op"
+)
+
+// setFakeOp puts an executable named op running script first in PATH.
+func setFakeOp(t *testing.T, script string) {
+  if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+    t.Skip("fake op requires a posix shell")
+  }
+
+  dir := t.TempDir()
+  require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "op"), []byte("#!/bin/sh\n" + script + "\n"), 0755))
+
+  path := os.Getenv("PATH")
+  os.Setenv("PATH", dir + string(os.PathListSeparator) + path)
+  t.Cleanup(func() { os.Setenv("PATH", path) })
+}
+
+func TestWithInteractive(t *testing.T) {
+  ctx := context.Background()
+  require.False(t, op.IsInteractive(ctx))
+  require.True(t, op.IsInteractive(op.WithInteractive(ctx)))
+
+  var interactive bool
+  _, err := op.Signin(context.Background(), func(ctx context.Context, stdin string, args []string) (string, error) {
+    interactive = op.IsInteractive(ctx)
+    return "session-token", nil
+  }, "")
+  require.Nil(t, err)
+  require.True(t, interactive)
+
+  _, err = op.Version(context.Background(), func(ctx context.Context, stdin string, args []string) (string, error) {
+    interactive = op.IsInteractive(ctx)
+    return "1.12.4", nil
+  })
+  require.Nil(t, err)
+  require.False(t, interactive)
+}
+
+func TestWithTimeout(t *testing.T) {
+  deadlineFunc := func(ctx context.Context, stdin string, args []string) (string, error) {
+    deadline, ok := ctx.Deadline()
+    if !ok {
+      return "none", nil
+    }
+    return time.Until(deadline).Round(time.Minute).String(), nil
+  }
+
+  opFunc := op.WithTimeout(deadlineFunc, time.Minute, 5 * time.Minute)
+  output, err := opFunc(context.Background(), "", nil)
+  require.Nil(t, err)
+  require.Equal(t, "1m0s", output)
+  output, err = opFunc(op.WithInteractive(context.Background()), "", nil)
+  require.Nil(t, err)
+  require.Equal(t, "5m0s", output)
+
+  // a zero timeout disables it
+  output, err = op.WithTimeout(deadlineFunc, 0, 0)(context.Background(), "", nil)
+  require.Nil(t, err)
+  require.Equal(t, "none", output)
+}
+
+func TestOp(t *testing.T) {
+  setFakeOp(t, `read line; echo "$1 $line"`)
+
+  output, err := op.Op(context.Background(), "stdin", []string{"get"})
+  require.Nil(t, err)
+  require.Equal(t, "get stdin", output)
+}
+
+func TestOpStderr(t *testing.T) {
+  // warnings on stderr are kept out of the output
+  setFakeOp(t, `echo "[LOG] 2021/04/29 14:42:46 (I) a new version of op is available" >&2; echo "document content"`)
+
+  output, err := op.Op(context.Background(), "", []string{"get", "document", "npm"})
+  require.Nil(t, err)
+  require.Equal(t, "document content", output)
+
+  stderr := bytes.Buffer{}
+  output, err = op.Op(op.WithStderr(context.Background(), &stderr), "", []string{"get", "document", "npm"})
+  require.Nil(t, err)
+  require.Equal(t, "document content", output)
+  require.Equal(t, "[LOG] 2021/04/29 14:42:46 (I) a new version of op is available\n", stderr.String())
+
+  // errors are classified from stderr, even if preceded by warnings
+  setFakeOp(t, `echo "partial"; echo "[LOG] 2021/04/29 14:42:46 (I) a new version of op is available" >&2; echo '[ERROR] 2021/04/29 14:42:46 "npm" doesn'"'"'t seem to be a document.' >&2; exit 1`)
+  _, err = op.Op(context.Background(), "", []string{"get", "document", "npm"})
+  require.True(t, errors.Is(err, op.ErrNotFound))
+
+  // unrecognized errors include stderr rather than just the exit status
+  setFakeOp(t, `echo "something went wrong" >&2; exit 1`)
+  _, err = op.Op(context.Background(), "", []string{"get", "document", "npm", "--session", "session-token"})
+  require.NotNil(t, err)
+  require.Equal(t, "op get document npm failed: something went wrong", err.Error())
+}
+
+func TestOpTimeout(t *testing.T) {
+  // op's children must be killed too, otherwise they
+  // keep its output open and block Op from returning
+  setFakeOp(t, "sleep 10 &\nwait")
+
+  start := time.Now()
+  _, err := op.WithTimeout(op.Op, 100 * time.Millisecond, 0)(context.Background(), "", []string{"get", "item", "github", "--session", "session-token"})
+  require.NotNil(t, err)
+  require.True(t, errors.Is(err, context.DeadlineExceeded))
+  require.Equal(t, "op get item github timed out: context deadline exceeded", err.Error())
+  require.Less(t, int64(time.Since(start)), int64(5 * time.Second))
+
+  ctx, cancel := context.WithCancel(context.Background())
+  time.AfterFunc(100 * time.Millisecond, cancel)
+  _, err = op.Op(ctx, "", []string{"list", "documents"})
+  require.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,141 @@
+package op
+
+import (
+  "encoding/json"
+  "fmt"
+  "time"
+)
+
+// Account is the output of "op get account".
+type Account struct {
+  UUID   string `json:"uuid"`
+  Name   string `json:"name"`
+  Domain string `json:"domain"`
+  Type   string `json:"type"`
+}
+
+// Vault is the output of "op get vault" and "op create vault".
+type Vault struct {
+  UUID        string `json:"uuid"`
+  Name        string `json:"name"`
+  Description string `json:"desc"`
+  Type        string `json:"type"`
+}
+
+// Overview holds an item's title and other unencrypted metadata.
+type Overview struct {
+  Title string   `json:"title"`
+  URL   string   `json:"url"`
+  Tags  []string `json:"tags"`
+}
+
+// Field is a single field in an item's details, e.g. a login's password.
+type Field struct {
+  Designation string `json:"designation"`
+  Name        string `json:"name"`
+  Type        string `json:"type"`
+  Value       string `json:"value"`
+}
+
+// ItemDetails holds an item's fields.
+type ItemDetails struct {
+  Fields []Field `json:"fields"`
+}
+
+// Item is the output of "op get item".
+type Item struct {
+  UUID         string      `json:"uuid"`
+  TemplateUUID string      `json:"templateUuid"`
+  VaultUUID    string      `json:"vaultUuid"`
+  CreatedAt    time.Time   `json:"createdAt"`
+  UpdatedAt    time.Time   `json:"updatedAt"`
+  Overview     Overview    `json:"overview"`
+  Details      ItemDetails `json:"details"`
+}
+
+// Document is a single document listed by "op list documents",
+// or the output of "op create document". A document's content
+// is not included and is read with "op get document".
+type Document struct {
+  UUID      string    `json:"uuid"`
+  VaultUUID string    `json:"vaultUuid"`
+  CreatedAt time.Time `json:"createdAt"`
+  UpdatedAt time.Time `json:"updatedAt"`
+  Overview  Overview  `json:"overview"`
+}
+
+// UnexpectedOutputError is returned when op's output cannot
+// be decoded or is missing fields which are always expected.
+type UnexpectedOutputError struct {
+  Command string
+  Reason  string
+}
+
+func (err *UnexpectedOutputError) Error() string {
+  return fmt.Sprintf("unexpected output from op %s: %s", err.Command, err.Reason)
+}
+
+// Field returns the value of the item's field with the provided
+// designation, e.g. "password", or name if no field has it.
+func (item Item) Field(designationOrName string) (string, bool) {
+  for _, field := range item.Details.Fields {
+    if field.Designation == designationOrName {
+      return field.Value, true
+    }
+  }
+  for _, field := range item.Details.Fields {
+    if field.Name == designationOrName {
+      return field.Value, true
+    }
+  }
+  return "", false
+}
+
+// uuidProbe reads the identifiers of any op json object, so that objects
+// missing a uuid can be told apart from objects written by op 2.x, which
+// are identified by "id" instead.
+type uuidProbe struct {
+  UUID string `json:"uuid"`
+  ID   string `json:"id"`
+}
+
+// decodeObject unmarshals the output of command into v, which must be a
+// pointer to a struct with a uuid, and checks that the uuid is present.
+func decodeObject(command string, output string, v interface{}) error {
+  if err := json.Unmarshal([]byte(output), v); err != nil {
+    return &UnexpectedOutputError{Command: command, Reason: err.Error()}
+  }
+
+  probe := uuidProbe{}
+  json.Unmarshal([]byte(output), &probe)
+  return checkUUID(command, probe)
+}
+
+// decodeArray unmarshals the output of command into v, which must be a
+// pointer to a slice of structs with a uuid, and checks that every
+// element's uuid is present.
+func decodeArray(command string, output string, v interface{}) error {
+  if err := json.Unmarshal([]byte(output), v); err != nil {
+    return &UnexpectedOutputError{Command: command, Reason: err.Error()}
+  }
+
+  probes := []uuidProbe{}
+  json.Unmarshal([]byte(output), &probes)
+  for _, probe := range probes {
+    if err := checkUUID(command, probe); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// checkUUID returns an UnexpectedOutputError if probe has no uuid.
+func checkUUID(command string, probe uuidProbe) error {
+  if probe.UUID != "" {
+    return nil
+  }
+  if probe.ID != "" {
+    return &UnexpectedOutputError{Command: command, Reason: "found \"id\" instead of \"uuid\", only op 1.x is supported"}
+  }
+  return &UnexpectedOutputError{Command: command, Reason: "missing uuid"}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "os/exec"
+  "os/signal"
+  "strings"
+  "syscall"
+
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// RunCommand resolves each --env NAME=mode:key#field reference, along with
+// each credential with an env in the nearest .credentials file if --manifest
+// is provided, and runs the provided command with those environment
+// variables set. Unless disabled, any resolved secret written to the
+// command's stdout/stderr is masked. Exits with the command's exit code.
+func RunCommand(ctx *util.Context, args []string) error {
+  env, secrets, err := resolveEnv(ctx, ctx.Flags.Run_Env)
+  if err != nil {
+    return err
+  }
+
+  if ctx.Flags.Run_Manifest {
+    manifestEnv, manifestSecrets, err := resolveManifestEnv(ctx)
+    if err != nil {
+      return err
+    }
+    env = append(manifestEnv, env...)
+    secrets = append(secrets, manifestSecrets...)
+  }
+
+  var stdout io.WriteCloser = nopWriteCloser{os.Stdout}
+  var stderr io.WriteCloser = nopWriteCloser{os.Stderr}
+  if !ctx.Flags.Run_NoMasking {
+    stdout = util.NewMaskedWriter(os.Stdout, secrets)
+    stderr = util.NewMaskedWriter(os.Stderr, secrets)
+  }
+
+  cmd := exec.Command(args[0], args[1:]...)
+  cmd.Env = append(os.Environ(), env...)
+  cmd.Stdin = os.Stdin
+  cmd.Stdout = stdout
+  cmd.Stderr = stderr
+
+  return runAndExit(cmd, stdout, stderr)
+}
+
+// resolveEnv resolves each NAME=reference pair into a NAME=value environment
+// variable, returning the variables along with the resolved secret values.
+func resolveEnv(ctx *util.Context, pairs []string) ([]string, []string, error) {
+  env := []string{}
+  secrets := []string{}
+  for _, pair := range pairs {
+    elements := strings.SplitN(pair, "=", 2)
+    if len(elements) != 2 || elements[0] == "" {
+      return nil, nil, fmt.Errorf("invalid env %s: expected NAME=mode:key", pair)
+    }
+
+    ref, err := util.ParseReference(elements[1])
+    if err != nil {
+      return nil, nil, err
+    }
+
+    value, err := ctx.Resolve(ref)
+    if err != nil {
+      return nil, nil, err
+    }
+
+    env = append(env, fmt.Sprintf("%s=%s", elements[0], value))
+    secrets = append(secrets, value)
+  }
+  return env, secrets, nil
+}
+
+// resolveManifestEnv gets each credential with an env in the nearest
+// .credentials file, returning NAME=value environment variables along
+// with the resolved secret values.
+func resolveManifestEnv(ctx *util.Context) ([]string, []string, error) {
+  _, credentials, err := util.ReadCredentialsFile("")
+  if err != nil {
+    return nil, nil, err
+  }
+
+  withEnv := []util.Credential{}
+  for _, credential := range credentials {
+    if credential.Env != "" {
+      withEnv = append(withEnv, credential)
+    }
+  }
+  if len(withEnv) == 0 {
+    return []string{}, []string{}, nil
+  }
+
+  withEnv, documents, err := ctx.GetCredentials(withEnv)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  env := []string{}
+  secrets := []string{}
+  for i, credential := range withEnv {
+    value, err := util.ResolveCredentialEnv(credential, documents[i])
+    if err != nil {
+      return nil, nil, err
+    }
+    env = append(env, fmt.Sprintf("%s=%s", credential.Env, value))
+    secrets = append(secrets, value)
+  }
+  return env, secrets, nil
+}
+
+// forwardedSignals are the signals runAndExit forwards to its command,
+// which are usually sent to this process alone, e.g. by kill.
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// terminalSignals are delivered by the terminal to its whole foreground
+// process group, which already includes the command, so they are only
+// caught to keep running until the command exits and are not forwarded.
+var terminalSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT}
+
+// runAndExit starts cmd, forwards any of forwardedSignals received to it,
+// waits for it to exit, flushes the provided writers and then exits with
+// cmd's exit code.
+func runAndExit(cmd *exec.Cmd, writers ...io.Closer) error {
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, append(append([]os.Signal{}, forwardedSignals...), terminalSignals...)...)
+  defer signal.Stop(signals)
+
+  if err := cmd.Start(); err != nil {
+    return err
+  }
+
+  go func() {
+    for sig := range signals {
+      if isForwardedSignal(sig) {
+        cmd.Process.Signal(sig)
+      }
+    }
+  }()
+
+  err := cmd.Wait()
+  for _, writer := range writers {
+    writer.Close()
+  }
+
+  if exitErr, ok := err.(*exec.ExitError); ok {
+    os.Exit(exitErr.ExitCode())
+  }
+  return err
+}
+
+// isForwardedSignal returns whether sig is one of forwardedSignals.
+func isForwardedSignal(sig os.Signal) bool {
+  for _, forwardedSignal := range forwardedSignals {
+    if sig == forwardedSignal {
+      return true
+    }
+  }
+  return false
+}
+
+type nopWriteCloser struct {
+  io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,161 @@
+package test
+
+import (
+  "bytes"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/op/optest"
+)
+
+// binDir holds the built cli, its mode scripts and the fake op binary.
+var binDir string
+
+func TestMain(m *testing.M) {
+  os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+  if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+    fmt.Println("skipping end-to-end tests which require a posix shell")
+    return 0
+  }
+
+  dir, err := ioutil.TempDir("", "credential-1password-test")
+  if err != nil {
+    fmt.Println(err.Error())
+    return 1
+  }
+  defer os.RemoveAll(dir)
+
+  if err := install(dir); err != nil {
+    fmt.Println(err.Error())
+    return 1
+  }
+  binDir = dir
+  return m.Run()
+}
+
+// install builds the cli, with the e2e tag so that it reads its keystore
+// from a file, and the fake op binary into dir, along with the scripts
+// which run the cli in each mode.
+func install(dir string) error {
+  cmd := exec.Command("go", "build", "-tags", "e2e", "-o", filepath.Join(dir, "credential-1password"), ".")
+  cmd.Dir = ".."
+  if output, err := cmd.CombinedOutput(); err != nil {
+    return fmt.Errorf("unable to build credential-1password: %s\n%s", err.Error(), string(output))
+  }
+
+  if _, err := optest.Install(dir); err != nil {
+    return err
+  }
+
+  scripts, err := filepath.Glob(filepath.Join("..", "scripts", "*-credential-1password"))
+  if err != nil {
+    return err
+  }
+  for _, script := range scripts {
+    content, err := ioutil.ReadFile(script)
+    if err != nil {
+      return err
+    }
+    if err := ioutil.WriteFile(filepath.Join(dir, filepath.Base(script)), content, 0755); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// cli runs commands in a temporary HOME, with the built cli and the fake
+// op binary first in PATH and the keystore kept in a file.
+type cli struct {
+  t         *testing.T
+  home      string
+  statePath string
+  env       []string
+}
+
+func newCLI(t *testing.T) *cli {
+  home := t.TempDir()
+  statePath := filepath.Join(home, "op-state.json")
+  return &cli{
+    t:         t,
+    home:      home,
+    statePath: statePath,
+    env: []string{
+      fmt.Sprintf("HOME=%s", home),
+      fmt.Sprintf("PATH=%s%c%s", binDir, os.PathListSeparator, os.Getenv("PATH")),
+      fmt.Sprintf("%s=%s", optest.StateEnv, statePath),
+      fmt.Sprintf("CREDENTIAL_1PASSWORD_KEYSTORE_FILE=%s", filepath.Join(home, "keystore.json")),
+      fmt.Sprintf("XDG_CONFIG_HOME=%s", filepath.Join(home, ".config")),
+      "GIT_CONFIG_NOSYSTEM=1",
+      "GIT_TERMINAL_PROMPT=0",
+    },
+  }
+}
+
+// run runs name with args and stdin in dir, or HOME if dir is empty, and
+// returns its stdout or an error including its stderr.
+func (c *cli) run(dir string, stdin string, env []string, name string, args ...string) (string, error) {
+  cmd := exec.Command(name, args...)
+  cmd.Dir = dir
+  if dir == "" {
+    cmd.Dir = c.home
+  }
+  cmd.Env = append(append([]string{}, c.env...), env...)
+  cmd.Stdin = strings.NewReader(stdin)
+
+  stdout := bytes.Buffer{}
+  stderr := bytes.Buffer{}
+  cmd.Stdout = &stdout
+  cmd.Stderr = &stderr
+  if err := cmd.Run(); err != nil {
+    return stdout.String(), fmt.Errorf("%s %s: %s\n%s", name, strings.Join(args, " "), err.Error(), stderr.String())
+  }
+  return stdout.String(), nil
+}
+
+// mustRun runs name like run and fails the test on error.
+func (c *cli) mustRun(dir string, name string, args ...string) string {
+  output, err := c.run(dir, "", nil, name, args...)
+  require.Nil(c.t, err)
+  return output
+}
+
+// simulator loads the fake op binary's current state.
+func (c *cli) simulator() *optest.Simulator {
+  sim, err := optest.LoadSimulator(c.statePath)
+  require.Nil(c.t, err)
+  return sim
+}
+
+// setSimulator replaces the fake op binary's state.
+func (c *cli) setSimulator(sim *optest.Simulator) {
+  require.Nil(c.t, sim.Save(c.statePath))
+}
+
+// document returns the content of the document with the provided
+// title in the vault which credential-1password creates by default.
+func (c *cli) document(title string) (string, bool) {
+  return c.simulator().Document(optest.DefaultAccount, "credential-1password", title)
+}
+
+func TestAccountFlag(t *testing.T) {
+  c := newCLI(t)
+
+  // a "." in a shorthand would split the keystore keys namespaced by account
+  _, err := c.run("", "", nil, filepath.Join(binDir, "credential-1password"), "--account", "my.work", "status")
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "invalid account shorthand 'my.work'")
+
+  output, err := c.run("", "", nil, filepath.Join(binDir, "credential-1password"), "--account", "work", "status")
+  require.Nil(t, err)
+  require.Contains(t, output, "account shorthand: work")
+}
@@ -0,0 +1,258 @@
+package test
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "regexp"
+  "strings"
+  "sync"
+  "testing"
+
+  "github.com/docker/docker-credential-helpers/client"
+  "github.com/docker/docker-credential-helpers/credentials"
+  "github.com/stretchr/testify/require"
+)
+
+// registryServer is a stand-in for a registry's token authentication:
+// /v2/ requires a bearer token, which /token issues for Basic auth.
+type registryServer struct {
+  *httptest.Server
+  mu       sync.Mutex
+  username string
+  password string
+  tokens   map[string]bool
+}
+
+func newRegistryServer(t *testing.T, username string, password string) *registryServer {
+  server := &registryServer{username: username, password: password, tokens: map[string]bool{}}
+  mux := http.NewServeMux()
+  mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+    server.mu.Lock()
+    authorized := server.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+    server.mu.Unlock()
+
+    if !authorized {
+      w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, server.URL))
+      http.Error(w, `{"errors":[{"code":"UNAUTHORIZED"}]}`, http.StatusUnauthorized)
+      return
+    }
+    w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+    w.Write([]byte("{}"))
+  })
+  mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+    server.mu.Lock()
+    defer server.mu.Unlock()
+    username, password, ok := r.BasicAuth()
+    if !ok || username != server.username || password != server.password {
+      http.Error(w, `{"details":"incorrect username or password"}`, http.StatusUnauthorized)
+      return
+    }
+    token := fmt.Sprintf("token-%d", len(server.tokens))
+    server.tokens[token] = true
+    json.NewEncoder(w).Encode(map[string]string{"token": token})
+  })
+  server.Server = httptest.NewServer(mux)
+  t.Cleanup(server.Close)
+  return server
+}
+
+// host returns the server's address as passed to `docker login`.
+func (server *registryServer) host() string {
+  return strings.TrimPrefix(server.URL, "http://")
+}
+
+var bearerRealmRegexp = regexp.MustCompile(`realm="([^"]+)"`)
+
+// authenticate pings the registry like docker does before a pull or
+// push and, if challenged, exchanges username and secret for a token.
+func (server *registryServer) authenticate(username string, secret string) error {
+  response, err := http.Get(fmt.Sprintf("%s/v2/", server.URL))
+  if err != nil {
+    return err
+  }
+  response.Body.Close()
+  if response.StatusCode == http.StatusOK {
+    return nil
+  }
+
+  match := bearerRealmRegexp.FindStringSubmatch(response.Header.Get("WWW-Authenticate"))
+  if match == nil {
+    return fmt.Errorf("unexpected challenge %q", response.Header.Get("WWW-Authenticate"))
+  }
+  request, err := http.NewRequest(http.MethodGet, match[1], nil)
+  if err != nil {
+    return err
+  }
+  request.SetBasicAuth(username, secret)
+  response, err = http.DefaultClient.Do(request)
+  if err != nil {
+    return err
+  }
+  defer response.Body.Close()
+  if response.StatusCode != http.StatusOK {
+    return fmt.Errorf("unauthorized: %s", response.Status)
+  }
+
+  body := struct{ Token string `json:"token"` }{}
+  if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+    return err
+  }
+
+  request, err = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v2/", server.URL), nil)
+  if err != nil {
+    return err
+  }
+  request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", body.Token))
+  response, err = http.DefaultClient.Do(request)
+  if err != nil {
+    return err
+  }
+  response.Body.Close()
+  if response.StatusCode != http.StatusOK {
+    return fmt.Errorf("token rejected: %s", response.Status)
+  }
+  return nil
+}
+
+// dockerHelperPath is the path of the docker credential helper script, since
+// commands are looked up in the test's PATH rather than the cli's.
+func dockerHelperPath() string {
+  return filepath.Join(binDir, "docker-credential-1password")
+}
+
+// dockerHelper returns a docker credential helper client which runs
+// docker-credential-1password with the cli's env, as docker does.
+func (c *cli) dockerHelper() client.ProgramFunc {
+  env := map[string]string{}
+  for _, variable := range c.env {
+    elements := strings.SplitN(variable, "=", 2)
+    env[elements[0]] = elements[1]
+  }
+  return client.NewShellProgramFuncWithEnv(dockerHelperPath(), &env)
+}
+
+// dockerLogin authenticates with the registry and stores the credentials
+// on success, as `docker login` does when a credsStore is configured.
+func dockerLogin(helper client.ProgramFunc, server *registryServer, username string, secret string) error {
+  if err := server.authenticate(username, secret); err != nil {
+    return err
+  }
+  return client.Store(helper, &credentials.Credentials{ServerURL: server.host(), Username: username, Secret: secret})
+}
+
+// dockerPull authenticates with the registry using the stored credentials,
+// which docker treats as anonymous if they are not found.
+func dockerPull(helper client.ProgramFunc, server *registryServer) error {
+  creds, err := client.Get(helper, server.host())
+  if credentials.IsErrCredentialsNotFound(err) {
+    return server.authenticate("", "")
+  }
+  if err != nil {
+    return err
+  }
+  return server.authenticate(creds.Username, creds.Secret)
+}
+
+func TestDockerCredential(t *testing.T) {
+  c := newCLI(t)
+  helper := c.dockerHelper()
+  serverURL := "registry.example.com"
+
+  // missing credentials are reported with the protocol's error message on stdout
+  _, err := client.Get(helper, serverURL)
+  require.True(t, credentials.IsErrCredentialsNotFound(err))
+  output, err := c.run("", serverURL, nil, dockerHelperPath(), "get")
+  require.NotNil(t, err)
+  require.Equal(t, "credentials not found in native keychain\n", output)
+
+  servers, err := client.List(helper)
+  require.Nil(t, err)
+  require.Equal(t, map[string]string{}, servers)
+
+  // stored json is returned as is, including characters which need escaping
+  secret := `pass"word\with=unicode-✓`
+  err = client.Store(helper, &credentials.Credentials{ServerURL: serverURL, Username: "username", Secret: secret})
+  require.Nil(t, err)
+  document, ok := c.document("docker:https://registry.example.com")
+  require.True(t, ok)
+  require.Contains(t, document, `"Username":"username"`)
+
+  creds, err := client.Get(helper, serverURL)
+  require.Nil(t, err)
+  require.Equal(t, &credentials.Credentials{ServerURL: serverURL, Username: "username", Secret: secret}, creds)
+
+  output, err = c.run("", serverURL, nil, dockerHelperPath(), "get")
+  require.Nil(t, err)
+  fields := map[string]string{}
+  require.Nil(t, json.Unmarshal([]byte(output), &fields))
+  require.Equal(t, map[string]string{"ServerURL": serverURL, "Username": "username", "Secret": secret}, fields)
+
+  // other spellings of the same registry share the credential
+  for _, alias := range []string{"https://registry.example.com", "https://registry.example.com:443/v2/", "REGISTRY.example.com"} {
+    creds, err = client.Get(helper, alias)
+    require.Nil(t, err)
+    require.Equal(t, secret, creds.Secret)
+  }
+
+  servers, err = client.List(helper)
+  require.Nil(t, err)
+  require.Equal(t, map[string]string{"https://registry.example.com": "username"}, servers)
+
+  // invalid credentials are rejected with the protocol's error messages
+  err = client.Store(helper, &credentials.Credentials{Username: "username", Secret: secret})
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "no credentials server URL")
+  err = client.Store(helper, &credentials.Credentials{ServerURL: serverURL, Secret: secret})
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "no credentials username")
+
+  require.Nil(t, client.Erase(helper, serverURL))
+  _, err = client.Get(helper, serverURL)
+  require.True(t, credentials.IsErrCredentialsNotFound(err))
+  _, ok = c.document("docker:https://registry.example.com")
+  require.False(t, ok)
+
+  // erasing missing credentials succeeds, as `docker logout` expects
+  require.Nil(t, client.Erase(helper, serverURL))
+
+  // commands other than the helper verbs report errors on stderr as usual
+  output, err = c.run("", "", nil, dockerHelperPath(), "config", "unknown")
+  require.NotNil(t, err)
+  require.Equal(t, "", output)
+  require.Contains(t, err.Error(), "unknown config option unknown")
+}
+
+func TestDockerLogin(t *testing.T) {
+  server := newRegistryServer(t, "username", "password")
+  c := newCLI(t)
+  helper := c.dockerHelper()
+
+  // anonymous pulls are refused before logging in
+  require.NotNil(t, dockerPull(helper, server))
+
+  // failed logins aren't stored
+  require.NotNil(t, dockerLogin(helper, server, "username", "wrong-password"))
+  _, err := client.Get(helper, server.host())
+  require.True(t, credentials.IsErrCredentialsNotFound(err))
+
+  require.Nil(t, dockerLogin(helper, server, "username", "password"))
+  document, ok := c.document(fmt.Sprintf("docker:%s", strings.Replace(server.URL, "http://", "https://", 1)))
+  require.True(t, ok)
+  fields := map[string]string{}
+  require.Nil(t, json.Unmarshal([]byte(document), &fields))
+  require.Equal(t, map[string]string{"ServerURL": server.host(), "Username": "username", "Secret": "password"}, fields)
+
+  require.Nil(t, dockerPull(helper, server))
+
+  // the helper signs in again once the stored session has expired in 1Password
+  sim := c.simulator()
+  sim.ExpireSessions()
+  c.setSimulator(sim)
+  require.Nil(t, dockerPull(helper, server))
+
+  require.Nil(t, client.Erase(helper, server.host()))
+  require.NotNil(t, dockerPull(helper, server))
+}
@@ -0,0 +1,191 @@
+package test
+
+import (
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "net/http/cgi"
+  "net/http/httptest"
+  "os/exec"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+)
+
+// gitServer is a smart-HTTP git server backed by git-http-backend
+// which requires Basic auth for every request.
+type gitServer struct {
+  *httptest.Server
+  mu           sync.Mutex
+  username     string
+  password     string
+  unauthorized int
+}
+
+func newGitServer(t *testing.T, username string, password string) *gitServer {
+  root := t.TempDir()
+  output, err := exec.Command("git", "init", "--bare", filepath.Join(root, "repo.git")).CombinedOutput()
+  require.Nil(t, err, string(output))
+  output, err = exec.Command("git", "-C", filepath.Join(root, "repo.git"), "config", "http.receivepack", "true").CombinedOutput()
+  require.Nil(t, err, string(output))
+
+  execPath, err := exec.Command("git", "--exec-path").Output()
+  require.Nil(t, err)
+  backend := &cgi.Handler{
+    Path:       filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+    Env:        []string{fmt.Sprintf("GIT_PROJECT_ROOT=%s", root), "GIT_HTTP_EXPORT_ALL=1"},
+    InheritEnv: []string{"PATH"},
+  }
+
+  server := &gitServer{username: username, password: password}
+  server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    server.mu.Lock()
+    requestUsername, requestPassword, ok := r.BasicAuth()
+    authorized := ok && requestUsername == server.username && requestPassword == server.password
+    if !authorized {
+      server.unauthorized++
+    }
+    server.mu.Unlock()
+
+    if !authorized {
+      w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+      http.Error(w, "unauthorized", http.StatusUnauthorized)
+      return
+    }
+    backend.ServeHTTP(w, r)
+  }))
+  t.Cleanup(server.Close)
+  return server
+}
+
+// repoURL returns the url of the server's only repository.
+func (server *gitServer) repoURL() string {
+  return fmt.Sprintf("%s/repo.git", server.URL)
+}
+
+// key returns the title which git credentials for the server are stored with.
+func (server *gitServer) key() string {
+  return fmt.Sprintf("git:%s", server.URL)
+}
+
+func (server *gitServer) setPassword(password string) {
+  server.mu.Lock()
+  defer server.mu.Unlock()
+  server.password = password
+}
+
+// newGitCLI returns a cli whose git uses credential-1password as its only
+// credential helper, and an askpass script answering with the provided
+// username and password, for when no stored credential is found.
+func newGitCLI(t *testing.T) *cli {
+  c := newCLI(t)
+  c.mustRun("", "git", "config", "--global", "credential.helper", "1password")
+  c.mustRun("", "git", "config", "--global", "user.name", "test")
+  c.mustRun("", "git", "config", "--global", "user.email", "test@example.com")
+  c.mustRun("", "git", "config", "--global", "init.defaultBranch", "main")
+  return c
+}
+
+// askpassEnv returns the env which makes git prompt for credentials
+// with a script answering with the provided username and password.
+func (c *cli) askpassEnv(username string, password string) []string {
+  path := filepath.Join(c.home, "askpass")
+  script := fmt.Sprintf("#!/bin/sh\ncase \"$1\" in\n  Username*) echo %q ;;\n  *) echo %q ;;\nesac\n", username, password)
+  require.Nil(c.t, ioutil.WriteFile(path, []byte(script), 0755))
+  return []string{fmt.Sprintf("GIT_ASKPASS=%s", path)}
+}
+
+func TestGitCredential(t *testing.T) {
+  c := newGitCLI(t)
+  input := "protocol=https\nhost=git.example.com\nusername=username\npassword=pass=word\n\n"
+
+  // nothing is filled before a credential is stored
+  _, err := c.run("", "protocol=https\nhost=git.example.com\n\n", nil, "git", "credential", "fill")
+  require.NotNil(t, err)
+
+  _, err = c.run("", input, nil, "git", "credential", "approve")
+  require.Nil(t, err)
+  document, ok := c.document("git:https://git.example.com")
+  require.True(t, ok)
+  require.Contains(t, document, "password=pass=word")
+
+  // git's own key=value format is read back, including values containing "="
+  output, err := c.run("", "protocol=https\nhost=git.example.com\n\n", nil, "git", "credential", "fill")
+  require.Nil(t, err)
+  require.Contains(t, output, "username=username\n")
+  require.Contains(t, output, "password=pass=word\n")
+
+  // the url form, with a user and path, shares the same key
+  output, err = c.run("", "url=https://username@git.example.com/org/repo.git\n\n", nil, "git", "credential", "fill")
+  require.Nil(t, err)
+  require.Contains(t, output, "password=pass=word\n")
+
+  _, err = c.run("", input, nil, "git", "credential", "reject")
+  require.Nil(t, err)
+  _, ok = c.document("git:https://git.example.com")
+  require.False(t, ok)
+}
+
+func TestGitCloneAndPush(t *testing.T) {
+  server := newGitServer(t, "username", "password")
+  c := newGitCLI(t)
+
+  // without a stored credential git fails rather than prompting
+  _, err := c.run("", "", nil, "git", "clone", server.repoURL(), "unauthenticated")
+  require.NotNil(t, err)
+
+  // credentials git prompts for are stored once they succeed
+  _, err = c.run("", "", c.askpassEnv("username", "password"), "git", "clone", server.repoURL(), "first")
+  require.Nil(t, err)
+  document, ok := c.document(server.key())
+  require.True(t, ok)
+  require.Contains(t, document, "username=username")
+  require.Contains(t, document, "password=password")
+
+  // and filled from 1Password afterwards
+  _, err = c.run("", "", nil, "git", "clone", server.repoURL(), "second")
+  require.Nil(t, err)
+  second := filepath.Join(c.home, "second")
+  require.Nil(t, ioutil.WriteFile(filepath.Join(second, "README.md"), []byte("# repo\n"), 0644))
+  c.mustRun(second, "git", "add", "README.md")
+  c.mustRun(second, "git", "commit", "-m", "Add README")
+  _, err = c.run(second, "", nil, "git", "push", "origin", "HEAD:main")
+  require.Nil(t, err)
+
+  first := filepath.Join(c.home, "first")
+  _, err = c.run(first, "", nil, "git", "pull", "origin", "main")
+  require.Nil(t, err)
+  content, err := ioutil.ReadFile(filepath.Join(first, "README.md"))
+  require.Nil(t, err)
+  require.Equal(t, "# repo\n", string(content))
+
+  // credentials rejected with a 401 are erased
+  server.setPassword("new-password")
+  _, err = c.run(first, "", nil, "git", "ls-remote", "origin")
+  require.NotNil(t, err)
+  _, ok = c.document(server.key())
+  require.False(t, ok)
+
+  _, err = c.run(first, "", c.askpassEnv("username", "new-password"), "git", "ls-remote", "origin")
+  require.Nil(t, err)
+  document, ok = c.document(server.key())
+  require.True(t, ok)
+  require.Contains(t, document, "password=new-password")
+}
+
+func TestGitExpiredSession(t *testing.T) {
+  server := newGitServer(t, "username", "password")
+  c := newGitCLI(t)
+  _, err := c.run("", "", c.askpassEnv("username", "password"), "git", "clone", server.repoURL(), "repo")
+  require.Nil(t, err)
+
+  // the helper signs in again once the stored session has expired in 1Password
+  sim := c.simulator()
+  sim.ExpireSessions()
+  c.setSimulator(sim)
+  _, err = c.run(filepath.Join(c.home, "repo"), "", nil, "git", "ls-remote", "origin")
+  require.Nil(t, err)
+}
@@ -0,0 +1,11 @@
+module github.com/tlowerison/credential-1password/test
+
+go 1.16
+
+replace github.com/tlowerison/credential-1password/op => ../op/
+
+require (
+	github.com/docker/docker-credential-helpers v0.6.1
+	github.com/stretchr/testify v1.7.0
+	github.com/tlowerison/credential-1password/op v0.0.0-00010101000000-000000000000
+)
@@ -0,0 +1,266 @@
+package util
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/url"
+  "regexp"
+  "sort"
+  "strings"
+  "sync"
+)
+
+const AccountKey = "account"
+const RouteKey = "route"
+
+// keystore keys of the default account and routes, neither of which may
+// prefix the other or "accounts.<shorthand>", since the darwin keystore
+// stores every item in one json document addressed by dotted paths
+const defaultAccountKey = "default-account"
+const accountRoutesKey = "account-routes"
+
+var accountRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// AccountRoute routes credentials to the 1Password account with shorthand
+// Account. Rule is either a mode, e.g. "npm", or a predefined mode and an
+// ssh_config style host pattern, e.g. "git:*.example.com".
+type AccountRoute struct {
+  Rule    string
+  Account string
+}
+
+// Mode returns the mode the route applies to.
+func (route AccountRoute) Mode() Mode {
+  mode, _ := splitAccountRule(route.Rule)
+  return mode
+}
+
+// Pattern returns the host pattern the route applies to, if any.
+func (route AccountRoute) Pattern() string {
+  _, pattern := splitAccountRule(route.Rule)
+  return pattern
+}
+
+// Matches reports whether the route applies to a credential in the
+// provided mode whose key has the provided host.
+func (route AccountRoute) Matches(mode Mode, host string) bool {
+  if route.Mode() != mode {
+    return false
+  }
+  pattern := route.Pattern()
+  return pattern == "" || (host != "" && MatchSSHHost(pattern, host))
+}
+
+// accountSessions holds the session token of each account which a Context or
+// any of its forks has signed into, so that credentials routed to the same
+// account only read the keystore or sign in once per process. A nil
+// *accountSessions holds nothing.
+type accountSessions struct {
+  mu            sync.Mutex
+  sessionTokens map[string]string
+}
+
+func newAccountSessions() *accountSessions {
+  return &accountSessions{sessionTokens: map[string]string{}}
+}
+
+func (sessions *accountSessions) get(account string) string {
+  if sessions == nil {
+    return ""
+  }
+  sessions.mu.Lock()
+  defer sessions.mu.Unlock()
+  return sessions.sessionTokens[account]
+}
+
+func (sessions *accountSessions) set(account string, sessionToken string) {
+  if sessions == nil {
+    return
+  }
+  sessions.mu.Lock()
+  defer sessions.mu.Unlock()
+  sessions.sessionTokens[account] = sessionToken
+}
+
+// ValidateAccount checks that account is a valid 1Password account shorthand,
+// which also keeps it from splitting the keystore keys built by accountKey.
+func ValidateAccount(account string) error {
+  if !accountRegexp.MatchString(account) {
+    return fmt.Errorf("invalid account shorthand '%s'", account)
+  }
+  return nil
+}
+
+// ValidateAccountRule checks that rule is a valid mode, or a
+// predefined mode followed by ":" and a host pattern.
+func ValidateAccountRule(rule string) error {
+  mode, pattern := splitAccountRule(rule)
+  if !mode.Valid() {
+    return fmt.Errorf("invalid route '%s': unknown mode %s", rule, string(mode))
+  }
+  if strings.HasPrefix(rule, fmt.Sprintf("%s:", string(mode))) && pattern == "" {
+    return fmt.Errorf("invalid route '%s': missing host pattern", rule)
+  }
+  return nil
+}
+
+// GetAccount returns the shorthand of the 1Password account which the
+// current credential belongs to: the account passed with --account, or the
+// account of the most specific route matching the current mode and key,
+// or the configured default account. Returns an empty string if none
+// of them are set, in which case op's default account is used. Once
+// signed in, the account is fixed for the rest of ctx's lifetime.
+func (ctx *Context) GetAccount() string {
+  if ctx.account != nil {
+    return *ctx.account
+  }
+  if ctx.Flags.Account != "" {
+    return ctx.Flags.Account
+  }
+
+  // missing routes and accounts are returned as errors by some keystores
+  routes, _ := ctx.GetAccountRoutes()
+  mode := ctx.GetMode()
+  host := ctx.routeHost()
+  for _, route := range routes {
+    if route.Matches(mode, host) {
+      return route.Account
+    }
+  }
+
+  account, _ := ctx.GetDefaultAccount()
+  return account
+}
+
+// GetAccountRoutes returns the configured account routes,
+// sorted from most to least specific host pattern.
+func (ctx *Context) GetAccountRoutes() ([]AccountRoute, error) {
+  value, err := ctx.keystore.Get(accountRoutesKey)
+  if err != nil {
+    return nil, err
+  }
+
+  accounts := map[string]string{}
+  if value != "" {
+    if err := json.Unmarshal([]byte(value), &accounts); err != nil {
+      return nil, fmt.Errorf("unable to read account routes: %s", err.Error())
+    }
+  }
+
+  routes := []AccountRoute{}
+  for rule, account := range accounts {
+    routes = append(routes, AccountRoute{Rule: rule, Account: account})
+  }
+  sort.SliceStable(routes, func(i, j int) bool {
+    if len(routes[i].Pattern()) != len(routes[j].Pattern()) {
+      return len(routes[i].Pattern()) > len(routes[j].Pattern())
+    }
+    return routes[i].Rule < routes[j].Rule
+  })
+  return routes, nil
+}
+
+// GetDefaultAccount returns the shorthand of the configured default account.
+func (ctx *Context) GetDefaultAccount() (string, error) {
+  return ctx.keystore.Get(defaultAccountKey)
+}
+
+// SetAccountRoute routes credentials matching rule to the account with the
+// provided shorthand, or removes the route for rule if account is empty.
+func (ctx *Context) SetAccountRoute(rule string, account string) error {
+  if err := ValidateAccountRule(rule); err != nil {
+    return err
+  }
+  if account != "" {
+    if err := ValidateAccount(account); err != nil {
+      return err
+    }
+  }
+
+  // missing routes are returned as errors by some keystores
+  routes, _ := ctx.GetAccountRoutes()
+  accounts := map[string]string{}
+  for _, route := range routes {
+    accounts[route.Rule] = route.Account
+  }
+  if account == "" {
+    delete(accounts, rule)
+  } else {
+    accounts[rule] = account
+  }
+
+  value, err := json.Marshal(accounts)
+  if err != nil {
+    return err
+  }
+  return ctx.keystore.Set(accountRoutesKey, string(value))
+}
+
+// SetDefaultAccount sets the shorthand of the account used when no route
+// matches, or clears it to use op's default account if account is empty.
+func (ctx *Context) SetDefaultAccount(account string) error {
+  if account == "" {
+    return ctx.keystore.Delete(defaultAccountKey)
+  }
+  if err := ValidateAccount(account); err != nil {
+    return err
+  }
+  return ctx.keystore.Set(defaultAccountKey, account)
+}
+
+// accountKey namespaces a keystore key by the current account, so that each
+// account has its own session token and vault. Keys for op's default
+// account are left as is, which keeps them compatible with single
+// account setups.
+func (ctx *Context) accountKey(key string) string {
+  account := ctx.GetAccount()
+  if account == "" {
+    return key
+  }
+  return fmt.Sprintf("accounts.%s.%s", account, key)
+}
+
+// renewSession signs into ctx's account again after its session expired,
+// unless a Context sharing its sessions already has since.
+func (ctx *Context) renewSession() error {
+  expired := ctx.opCtx.SessionToken
+  if sessionToken := ctx.sessions.get(ctx.GetAccount()); sessionToken != "" && sessionToken != expired {
+    ctx.opCtx.SessionToken = sessionToken
+    return nil
+  }
+  _, err := ctx.Signin()
+  return err
+}
+
+// routeHost returns the host of the current credential's key which routes
+// are matched against: the hostname of url keys, and the key itself for
+// maven server ids and ssh host patterns. Returns an empty string if no
+// input has been read or the mode is not predefined.
+func (ctx *Context) routeHost() string {
+  mode := ctx.GetMode()
+  if ctx.input == "" || !mode.IsPredefined() {
+    return ""
+  }
+
+  key, err := ctx.GetKey()
+  if err != nil {
+    return ""
+  }
+  key = strings.TrimPrefix(key, fmt.Sprintf("%s:", string(mode)))
+
+  if URL, err := url.Parse(key); err == nil && URL.Host != "" {
+    return URL.Hostname()
+  }
+  return key
+}
+
+// splitAccountRule splits a route rule into its mode and host pattern.
+// Only predefined modes can have a host pattern, so a generic mode
+// containing ":" is returned as is.
+func splitAccountRule(rule string) (Mode, string) {
+  i := strings.Index(rule, ":")
+  if i == -1 || !Mode(rule[:i]).IsPredefined() {
+    return Mode(rule), ""
+  }
+  return Mode(rule[:i]), rule[i+1:]
+}
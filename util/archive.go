@@ -0,0 +1,196 @@
+package util
+
+import (
+  "crypto/aes"
+  "crypto/cipher"
+  "crypto/rand"
+  "encoding/json"
+  "fmt"
+  "sort"
+  "time"
+
+  "golang.org/x/crypto/scrypt"
+)
+
+const archiveVersion = 1
+const archiveKDF = "scrypt"
+
+// archiveAdditionalData binds the ciphertext to the archive format.
+var archiveAdditionalData = []byte("credential-1password-archive")
+
+// default scrypt parameters, as recommended for interactive logins in 2017
+const (
+  archiveScryptN = 1 << 15
+  archiveScryptR = 8
+  archiveScryptP = 1
+)
+
+// Archive is a backup of every document in a vault.
+type Archive struct {
+  CreatedAt time.Time         `json:"createdAt"`
+  Vault     string            `json:"vault"`
+  Documents []ArchiveDocument `json:"documents"`
+}
+
+// ArchiveDocument is a single backed up document.
+type ArchiveDocument struct {
+  Title    string `json:"title"`
+  FileName string `json:"fileName"`
+  Content  string `json:"content"`
+}
+
+// encryptedArchive is the serialized form of an Archive encrypted with
+// AES-256-GCM using a key derived from a passphrase with scrypt.
+type encryptedArchive struct {
+  Version    int    `json:"version"`
+  KDF        string `json:"kdf"`
+  N          int    `json:"n"`
+  R          int    `json:"r"`
+  P          int    `json:"p"`
+  Salt       []byte `json:"salt"`
+  Nonce      []byte `json:"nonce"`
+  Ciphertext []byte `json:"ciphertext"`
+}
+
+// Export reads every document in the configured vault into an Archive,
+// sorted by title. Since the vault is dedicated to credential-1password,
+// this includes documents added to it by other means, which restore as is.
+func (ctx *Context) Export() (Archive, error) {
+  vaultName, err := ctx.GetVaultName()
+  if err != nil {
+    return Archive{}, err
+  }
+
+  overviews, err := ctx.listDocumentOverviews()
+  if err != nil {
+    return Archive{}, err
+  }
+
+  documents := make([]ArchiveDocument, len(overviews))
+  errs := make([]error, len(overviews))
+  forEachConcurrently(len(overviews), func(i int) {
+    overview := overviews[i]
+    content, err := ctx.getDocument(overview.UUID)
+    if err != nil {
+      errs[i] = fmt.Errorf("unable to export %s: %s", overview.Overview.Title, err.Error())
+      return
+    }
+    documents[i] = ArchiveDocument{
+      Title:    overview.Overview.Title,
+      FileName: fmt.Sprintf("%s-credentials", string(parseListEntry(overview).Mode)),
+      Content:  content,
+    }
+  })
+
+  for _, err := range errs {
+    if err != nil {
+      return Archive{}, err
+    }
+  }
+
+  sort.SliceStable(documents, func(i, j int) bool { return documents[i].Title < documents[j].Title })
+  return Archive{
+    CreatedAt: time.Now().UTC(),
+    Vault:     vaultName,
+    Documents: documents,
+  }, nil
+}
+
+// Restore upserts every document in the archive into the configured vault,
+// replacing the content of any document with the same title.
+func (ctx *Context) Restore(archive Archive) error {
+  for _, document := range archive.Documents {
+    if err := ctx.upsertDocument(document.Title, document.FileName, document.Content); err != nil {
+      return fmt.Errorf("unable to restore %s: %s", document.Title, err.Error())
+    }
+  }
+  return nil
+}
+
+// EncryptArchive serializes and encrypts the archive with a key derived from
+// passphrase, such that it can only be read with DecryptArchive.
+func EncryptArchive(archive Archive, passphrase []byte) ([]byte, error) {
+  if len(passphrase) == 0 {
+    return nil, fmt.Errorf("passphrase must not be empty")
+  }
+
+  plaintext, err := json.Marshal(archive)
+  if err != nil {
+    return nil, err
+  }
+
+  encrypted := encryptedArchive{
+    Version: archiveVersion,
+    KDF:     archiveKDF,
+    N:       archiveScryptN,
+    R:       archiveScryptR,
+    P:       archiveScryptP,
+    Salt:    make([]byte, 16),
+  }
+  if _, err := rand.Read(encrypted.Salt); err != nil {
+    return nil, err
+  }
+
+  aead, err := newArchiveAEAD(encrypted, passphrase)
+  if err != nil {
+    return nil, err
+  }
+
+  encrypted.Nonce = make([]byte, aead.NonceSize())
+  if _, err := rand.Read(encrypted.Nonce); err != nil {
+    return nil, err
+  }
+  encrypted.Ciphertext = aead.Seal(nil, encrypted.Nonce, plaintext, archiveAdditionalData)
+
+  return json.MarshalIndent(encrypted, "", "  ")
+}
+
+// DecryptArchive decrypts and deserializes an archive written by EncryptArchive.
+func DecryptArchive(data []byte, passphrase []byte) (Archive, error) {
+  encrypted := encryptedArchive{}
+  if err := json.Unmarshal(data, &encrypted); err != nil {
+    return Archive{}, fmt.Errorf("invalid archive: %s", err.Error())
+  }
+  if encrypted.Version != archiveVersion || encrypted.KDF != archiveKDF {
+    return Archive{}, fmt.Errorf("unsupported archive version %d using %s", encrypted.Version, encrypted.KDF)
+  }
+  // only the parameters EncryptArchive writes are accepted, since the key
+  // derivation's memory and time grow with them before the passphrase is checked
+  if encrypted.N != archiveScryptN || encrypted.R != archiveScryptR || encrypted.P != archiveScryptP {
+    return Archive{}, fmt.Errorf("unsupported archive scrypt parameters n=%d r=%d p=%d", encrypted.N, encrypted.R, encrypted.P)
+  }
+
+  aead, err := newArchiveAEAD(encrypted, passphrase)
+  if err != nil {
+    return Archive{}, err
+  }
+  if len(encrypted.Nonce) != aead.NonceSize() {
+    return Archive{}, fmt.Errorf("invalid archive: invalid nonce")
+  }
+
+  plaintext, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, archiveAdditionalData)
+  if err != nil {
+    return Archive{}, fmt.Errorf("unable to decrypt archive: wrong passphrase or corrupted archive")
+  }
+
+  archive := Archive{}
+  if err := json.Unmarshal(plaintext, &archive); err != nil {
+    return Archive{}, fmt.Errorf("invalid archive: %s", err.Error())
+  }
+  return archive, nil
+}
+
+// newArchiveAEAD derives an AES-256-GCM cipher from passphrase
+// using the archive's scrypt parameters and salt.
+func newArchiveAEAD(encrypted encryptedArchive, passphrase []byte) (cipher.AEAD, error) {
+  key, err := scrypt.Key(passphrase, encrypted.Salt, encrypted.N, encrypted.R, encrypted.P, 32)
+  if err != nil {
+    return nil, err
+  }
+
+  block, err := aes.NewCipher(key)
+  if err != nil {
+    return nil, err
+  }
+  return cipher.NewGCM(block)
+}
@@ -10,6 +10,10 @@ import (
 
 type Runnable func(cmd *cobra.Command, args []string)
 
+// dockerHelperVerbs are the commands docker runs as a credential helper,
+// which report errors on stdout rather than stderr.
+var dockerHelperVerbs = []string{"get", "store", "erase", "list"}
+
 // PreRunWithInput wraps ctx.ParseInput with a session retry.
 func PreRunWithInput(ctx *Context) Runnable {
   return func(cmd *cobra.Command, args []string) {
@@ -42,9 +46,22 @@ func WithSessionRetry(ctx *Context, cmd *cobra.Command, args []string, fn func(c
     ctx.Signin()
     err = fn(ctx)
   }
+  if ctx.GetMode() == DockerMode && isDockerHelperVerb(cmd.Name()) {
+    handleDockerErr(err)
+  }
   HandleErr(err)
 }
 
+// isDockerHelperVerb returns whether the named command is one of dockerHelperVerbs.
+func isDockerHelperVerb(name string) bool {
+  for _, verb := range dockerHelperVerbs {
+    if name == verb {
+      return true
+    }
+  }
+  return false
+}
+
 // HandleErr does if nothing if the provided error is nil, otherwise
 // it prints the provided err's message to stderr and exits.
 func HandleErr(err error) {
@@ -53,3 +70,12 @@ func HandleErr(err error) {
     os.Exit(1)
   }
 }
+
+// handleDockerErr is HandleErr for the docker helper verbs: docker reads
+// a credential helper's error messages from stdout rather than stderr.
+func handleDockerErr(err error) {
+  if err != nil {
+    fmt.Fprintln(os.Stdout, err.Error())
+    os.Exit(1)
+  }
+}
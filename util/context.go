@@ -2,15 +2,17 @@ package util
 
 import (
   "bufio"
+  "context"
   "encoding/json"
+  "errors"
   "fmt"
   "io"
   "net/url"
   "strings"
+  "sync"
   "time"
 
   "github.com/spf13/cobra"
-  "github.com/tidwall/gjson"
   "github.com/tlowerison/credential-1password/keystore"
   "github.com/tlowerison/credential-1password/op"
 )
@@ -18,12 +20,37 @@ import (
 type Mode string
 
 type Flags struct {
-  Mode                string
-  Config_Vault_Create bool
+  Account              string
+  Mode                 string
+  OpCommandRetries     map[string]int
+  OpRetries            int
+  OpRetryMaxDelay      time.Duration
+  OpTimeout            time.Duration
+  SigninTimeout        time.Duration
+  Verbose              bool
+  Config_Vault_Create  bool
+  Config_Vault_Migrate bool
+  Config_Vault_Move    bool
+  Get_Fifo             string
+  Get_Format           string
+  Import_DryRun        bool
+  Import_Scrub         bool
+  Import_Yes           bool
+  Inject_Input         string
+  Inject_Output        string
+  List_Format          string
+  Run_Env              []string
+  Run_Manifest         bool
+  Run_NoMasking        bool
+  SSHAgent_Confirm     bool
+  SSHAgent_Lifetime    time.Duration
+  SSHAgent_Socket      string
 }
 
 type Context struct {
   Flags       *Flags
+  account     *string
+  baseCtx     context.Context
   cmd         *cobra.Command
   input       string
   inputs      map[string]string
@@ -35,20 +62,38 @@ type Context struct {
   opCtx       *op.Context
   password    string
   serviceName string
+  sessions    *accountSessions
   stdin       io.ReadCloser
   stdinDeadline time.Duration
   username    string
   vaultName   string
+  vaults      *op.VaultCache
 }
 
 const (
   DockerMode Mode = "docker"
   GitMode    Mode = "git"
+  GoauthMode Mode = "goauth"
+  MavenMode  Mode = "maven"
+  NetrcMode  Mode = "netrc"
+  SSHMode    Mode = "ssh"
 )
 
 var PredefinedModes = []string{
   string(DockerMode),
   string(GitMode),
+  string(GoauthMode),
+  string(MavenMode),
+  string(NetrcMode),
+  string(SSHMode),
+}
+
+// legacyPredefinedModes are the predefined modes which reserve any generic
+// mode they prefix, as they always have. Predefined modes added since only
+// reserve themselves, so that generic modes like ssh-keys remain valid.
+var legacyPredefinedModes = []string{
+  string(DockerMode),
+  string(GitMode),
 }
 
 const VaultKey = "vault"
@@ -57,6 +102,10 @@ func (m Mode) IsPredefined() bool {
   switch m {
   case DockerMode: break
   case GitMode: break
+  case GoauthMode: break
+  case MavenMode: break
+  case NetrcMode: break
+  case SSHMode: break
   default: return false
   }
   return true
@@ -70,30 +119,91 @@ const ErrMsgUnknownCommand = "unable to read inputs in the correct format withou
 const ErrMsgDockerServerUrlBadInputZeroLines = "cannot parse url from zero lines of input"
 const ErrMsgDockerServerUrlBadInputMultipleLines = "cannot parse url from multiple lines of input"
 const ErrMsgClosedStdinAfterDeadline = "closed stdin after waiting"
+
+// The docker credential helper protocol's error messages, which docker
+// matches on verbatim, e.g. to treat missing credentials as logged out.
+const ErrMsgDockerCredentialsNotFound = "credentials not found in native keychain"
+const ErrMsgDockerMissingServerURL = "no credentials server URL"
+const ErrMsgDockerMissingUsername = "no credentials username"
 const ServiceName = "com.tlowerison.credential-1password"
 
 const sessionTokenDateKey = "session-token.date"
 const sessionTokenValueKey = "session-token.value"
 
+// sessionIdleTimeout is how long 1Password session tokens remain valid when unused.
+const sessionIdleTimeout = 30 * time.Minute
+
+// maxConcurrentOpCalls is how many documents are read from op at once
+// when reading many, e.g. to export or list, to stay clear of rate limits.
+const maxConcurrentOpCalls = 8
+
 var vaultNameKey = fmt.Sprintf("%s.name", VaultKey)
 var vaultUUIDKey = fmt.Sprintf("%s.uuid", VaultKey)
 const vaultDescription = "Contains credentials managed by %s."
 const vaultNameDefault = "credential-1password"
 
 const dockerServerURLKey = "ServerURL"
+const dockerHubServerURL = "https://index.docker.io/v1/"
+
+// dockerHubHosts are the hostnames which all refer to Docker Hub.
+var dockerHubHosts = []string{
+  "docker.io",
+  "index.docker.io",
+  "registry-1.docker.io",
+  "registry.hub.docker.com",
+}
+
 const timeFormat = time.UnixDate
 const defaultStdinDeadline = 30 * time.Second
 
 func NewContext(opFunc op.OpFunc, ks keystore.Keystore, stdin io.ReadCloser) *Context {
   return &Context{
     Flags:        &Flags{},
+    baseCtx:      context.Background(),
     OpFunc:       opFunc,
     opCtx:        &op.Context{},
     inputs:       map[string]string{},
     keystore:     ks,
+    sessions:     newAccountSessions(),
     stdin:        stdin,
     stdinDeadline: defaultStdinDeadline,
+    vaults:       op.NewVaultCache(),
+  }
+}
+
+// DeleteDocument deletes the document keyed by the input provided over
+// stdin from the configured vault, along with any document stored under
+// the input's legacy key, see getLegacyKey. Returns op.ErrNotFound if
+// neither exists.
+func (ctx *Context) DeleteDocument() error {
+  query, err := ctx.GetOpQuery()
+  if err != nil {
+    return err
+  }
+
+  err = op.DeleteDocument(ctx.GetBaseContext(), ctx.OpFunc, *query)
+  if err != nil && !errors.Is(err, op.ErrNotFound) {
+    return err
+  }
+  if legacyKey := ctx.getLegacyKey(); legacyKey != "" && legacyKey != query.Key {
+    legacyErr := op.DeleteDocument(ctx.GetBaseContext(), ctx.OpFunc, op.Query{Context: query.Context, Key: legacyKey})
+    if !errors.Is(legacyErr, op.ErrNotFound) {
+      return legacyErr
+    }
+  }
+  if err == nil || errors.Is(err, op.ErrNotFound) {
+    ctx.setMissingDocument(query.VaultUUID, query.Key, true)
   }
+  return err
+}
+
+// GetBaseContext returns the context which every op call is
+// made with, or context.Background() if none has been set.
+func (ctx *Context) GetBaseContext() context.Context {
+  if ctx.baseCtx == nil {
+    return context.Background()
+  }
+  return ctx.baseCtx
 }
 
 // GetCmd returns the private cmd field.
@@ -101,6 +211,30 @@ func (ctx *Context) GetCmd() *cobra.Command {
   return ctx.cmd
 }
 
+// GetDocument gets the content of the document keyed by the input provided
+// over stdin from the configured vault, falling back to the input's legacy
+// key, see getLegacyKey, if no document is stored under its key.
+func (ctx *Context) GetDocument() (string, error) {
+  key, err := ctx.GetKey()
+  if err != nil {
+    return "", err
+  }
+
+  document, err := ctx.getDocument(key)
+  if errors.Is(err, op.ErrNotFound) {
+    // a store usually follows, which can then create the document directly
+    ctx.setMissingDocument(ctx.opCtx.VaultUUID, key, true)
+    if legacyKey := ctx.getLegacyKey(); legacyKey != "" && legacyKey != key {
+      if legacyDocument, legacyErr := ctx.getDocument(legacyKey); !errors.Is(legacyErr, op.ErrNotFound) {
+        return legacyDocument, legacyErr
+      }
+    }
+  } else if err == nil {
+    ctx.setMissingDocument(ctx.opCtx.VaultUUID, key, false)
+  }
+  return document, err
+}
+
 // GetInput returns the private field input.
 // input is the cached value of what is read
 // from stdin.
@@ -193,27 +327,38 @@ func (ctx *Context) GetOpQuery() (*op.Query, error) {
 // is, tries to return whatever is stored in the encrypted keystore. If there's
 // nothing in the keystore or the token is out of date, it will request the user
 // to sigin, store the newly created session token in the encrypted keystore
-// as well as context, and return the session token.
+// as well as context, and return the session token. Session tokens are
+// stored separately for each account, see GetAccount.
 func (ctx *Context) GetSessionToken() (string, error) {
   if ctx.opCtx == nil {
     ctx.opCtx = &op.Context{}
   }
 
+  // the account is fixed once its session is in use
+  account := ctx.GetAccount()
+  ctx.account = &account
+
   if ctx.opCtx.SessionToken != "" {
     return ctx.opCtx.SessionToken, nil
   }
 
-  sessionTokenDate, err := ctx.keystore.Get(sessionTokenDateKey)
+  // a fork may have already signed into the account
+  if sessionToken := ctx.sessions.get(account); sessionToken != "" {
+    ctx.opCtx.SessionToken = sessionToken
+    return sessionToken, nil
+  }
+
+  sessionTokenDate, err := ctx.keystore.Get(ctx.accountKey(sessionTokenDateKey))
   if err != nil || sessionTokenDate == "" {
     return ctx.Signin()
   }
 
   date, err := time.Parse(timeFormat, sessionTokenDate)
-  if err != nil || time.Now().Sub(date).Minutes() >= 30 {
+  if err != nil || time.Since(date) >= sessionIdleTimeout {
     return ctx.Signin()
   }
 
-  sessionToken, err := ctx.keystore.Get(sessionTokenValueKey)
+  sessionToken, err := ctx.keystore.Get(ctx.accountKey(sessionTokenValueKey))
 
   if err != nil {
     return "", err
@@ -222,9 +367,16 @@ func (ctx *Context) GetSessionToken() (string, error) {
   }
 
   ctx.opCtx.SessionToken = sessionToken
+  ctx.sessions.set(account, sessionToken)
   return ctx.opCtx.SessionToken, nil
 }
 
+// GetStdin returns the context's stdin, for commands which read
+// it themselves rather than as input scanned by ParseInput.
+func (ctx *Context) GetStdin() io.ReadCloser {
+  return ctx.stdin
+}
+
 // GetStdinDeadline returns the stdin timeout.
 func (ctx *Context) GetStdinDeadline() time.Duration {
   return ctx.stdinDeadline
@@ -237,7 +389,7 @@ func (ctx *Context) GetVaultName() (string, error) {
     return ctx.vaultName, nil
   }
 
-  vaultName, err := ctx.keystore.Get(vaultNameKey)
+  vaultName, err := ctx.keystore.Get(ctx.accountKey(vaultNameKey))
   if err != nil {
     return "", err
   }
@@ -248,7 +400,7 @@ func (ctx *Context) GetVaultName() (string, error) {
   }
 
   ctx.vaultName = vaultNameDefault
-  return vaultNameDefault, ctx.keystore.Set(vaultNameKey, vaultNameDefault)
+  return vaultNameDefault, ctx.keystore.Set(ctx.accountKey(vaultNameKey), vaultNameDefault)
 }
 
 // ParseInput scans from stdin and splits each line by "=" to find key/value pairs.
@@ -261,7 +413,7 @@ func (ctx *Context) ParseInput() (err error) {
 
   cmdName := strings.Split(ctx.GetCmd().Use, " ")[0]
   var lines []string
-  if !ctx.GetMode().IsPredefined() && cmdName != "store" {
+  if !ctx.shouldScanStdin(cmdName) {
     lines = []string{}
   } else {
     lines, err = ctx.scanStdinLines()
@@ -276,17 +428,28 @@ func (ctx *Context) ParseInput() (err error) {
   switch mode {
   case DockerMode:
     if cmdName == "store" {
-      return ctx.parseJSONInputs(lines)
+      if err := ctx.parseJSONInputs(lines); err != nil {
+        return err
+      }
+      return ctx.validateDockerInputs()
     } else {
       return ctx.parseServerURLInput(lines)
     }
-  case GitMode:
+  case GitMode, GoauthMode, MavenMode:
     return ctx.parseKeyValueInputs(lines)
+  case SSHMode:
+    return ctx.parseSSHInputs(lines)
   default:
     return nil
   }
 }
 
+// SetBaseContext sets the context which every op call is made with,
+// so that cancelling baseCtx stops any op call in progress.
+func (ctx *Context) SetBaseContext(baseCtx context.Context) {
+  ctx.baseCtx = baseCtx
+}
+
 // SetCmd sets the private cmd field.
 // cmd should be assigned by a prerun cobra command hook.
 func (ctx *Context) SetCmd(cmd *cobra.Command) {
@@ -304,31 +467,16 @@ func (ctx *Context) SetStdinDeadline(stdinDeadline time.Duration) {
 // 2b. if not, and shouldCreate is false, fails
 // 2c. if not, and shouldCreate is true, creates a new vault with the provided name, loop back to step 2a
 func (ctx *Context) SetVaultName(vaultName string, shouldCreate bool) error {
-  ctx.vaultName = vaultName
-  ctx.setVaultUUID("")
-
-  opCtx, err := ctx.getOpCtx()
-  if err != nil {
-    return err
-  }
-
-  vault, err := op.GetVault(ctx.OpFunc, op.Query{Context: *opCtx, Key: vaultName})
+  sessionToken, err := ctx.GetSessionToken()
   if err != nil {
     return err
   }
 
-  vaultUUID := gjson.Get(vault, "uuid").String()
-
-  if vaultUUID != "" {
-    ctx.setVaultName(vaultName)
-    ctx.setVaultUUID(vaultUUID)
-    return nil
-  }
-  if !shouldCreate {
-    return fmt.Errorf("unable to get specified vault's uuid")
+  vault, err := ctx.getVault(sessionToken, vaultName)
+  vaultUUID := vault.UUID
+  if errors.Is(err, op.ErrVaultNotFound) && shouldCreate {
+    vaultUUID, err = ctx.createVault(vaultName)
   }
-
-  vaultUUID, err = ctx.createVault(vaultName)
   if err != nil {
     return err
   }
@@ -341,7 +489,7 @@ func (ctx *Context) SetVaultName(vaultName string, shouldCreate bool) error {
 // Signin clears the current cached session token, requests the user to signin,
 // stores the new returned session token and returns it as well.
 func (ctx *Context) Signin() (string, error) {
-  sessionToken, err := op.Signin(ctx.OpFunc)
+  sessionToken, err := op.Signin(ctx.GetBaseContext(), ctx.OpFunc, ctx.GetAccount())
   if err != nil {
     return "", err
   }
@@ -354,6 +502,18 @@ func (ctx *Context) Signin() (string, error) {
   return sessionToken, nil
 }
 
+// Store upserts the document keyed by the input provided over stdin,
+// with the input as its content, in the configured vault.
+func (ctx *Context) Store() error {
+  key, err := ctx.GetKey()
+  if err != nil {
+    return err
+  }
+
+  fileName := fmt.Sprintf("%s-credentials", string(ctx.GetMode()))
+  return ctx.upsertDocument(key, fileName, ctx.GetInput())
+}
+
 
 // --- ctx helper fns ---
 
@@ -363,8 +523,9 @@ func (ctx *Context) clearSessionToken() {
     ctx.opCtx = &op.Context{}
   }
   ctx.opCtx.SessionToken = ""
-  ctx.keystore.Set(sessionTokenDateKey, "")
-  ctx.keystore.Set(sessionTokenValueKey, "")
+  ctx.sessions.set(ctx.GetAccount(), "")
+  ctx.keystore.Set(ctx.accountKey(sessionTokenDateKey), "")
+  ctx.keystore.Set(ctx.accountKey(sessionTokenValueKey), "")
 }
 
 // createVault gets a session token, attempts to create a 1Password vault
@@ -375,7 +536,7 @@ func (ctx *Context) createVault(vaultName string) (string, error) {
     return "", err
   }
 
-  output, err := op.CreateVault(ctx.OpFunc, op.CreateVaultMutation{
+  vault, err := op.CreateVault(ctx.GetBaseContext(), ctx.OpFunc, op.CreateVaultMutation{
     SessionToken: sessionToken,
     Title: vaultName,
     Description: fmt.Sprintf(vaultDescription, ctx.GetName()),
@@ -384,12 +545,48 @@ func (ctx *Context) createVault(vaultName string) (string, error) {
     return "", err
   }
 
-  vaultUUID := gjson.Get(output, "uuid").String()
-  if vaultUUID == "" {
-    return "", fmt.Errorf("unable to get specified vault's uuid")
+  ctx.vaults.Add(sessionToken, vault)
+  return vault.UUID, nil
+}
+
+// fork returns a new Context for running get in the provided mode with input
+// read from stdin, which shares ctx's keystore and --account flag. The fork's
+// account is resolved from its own mode and key once its input is read, so
+// routes apply to it as to any other credential; the session of each account
+// is shared between ctx and all of its forks, see accountSessions.
+func (ctx *Context) fork(mode Mode, stdin io.ReadCloser) (*Context, error) {
+  return &Context{
+    Flags:         &Flags{Account: ctx.Flags.Account, Mode: string(mode)},
+    baseCtx:       ctx.baseCtx,
+    cmd:           &cobra.Command{Use: "get"},
+    inputs:        map[string]string{},
+    keystore:      ctx.keystore,
+    OpFunc:        ctx.OpFunc,
+    opCtx:         &op.Context{},
+    sessions:      ctx.sessions,
+    stdin:         stdin,
+    stdinDeadline: ctx.stdinDeadline,
+    vaults:        ctx.vaults,
+  }, nil
+}
+
+// getDocument gets the content of the document with the provided
+// title or uuid from the configured vault.
+func (ctx *Context) getDocument(key string) (string, error) {
+  opCtx, err := ctx.getOpCtx()
+  if err != nil {
+    return "", err
   }
+  return op.GetDocument(ctx.GetBaseContext(), ctx.OpFunc, op.Query{Context: *opCtx, Key: key})
+}
 
-  return vaultUUID, nil
+// getVault gets the vault with the provided name or uuid, launching
+// op only if it hasn't been looked up yet by any forked Context.
+func (ctx *Context) getVault(sessionToken string, vaultName string) (op.Vault, error) {
+  return ctx.vaults.GetVault(ctx.GetBaseContext(), ctx.OpFunc, op.Query{
+    Context: op.Context{SessionToken: sessionToken},
+    Key: vaultName,
+  })
 }
 
 // getModeKey muxes different modes to derive a mode specific key from stdin input.
@@ -400,6 +597,12 @@ func (ctx *Context) getModeKey() (string, error) {
     return ctx.getDockerKey()
   case GitMode:
     return ctx.getGitKey()
+  case GoauthMode:
+    return ctx.getGoauthKey()
+  case MavenMode:
+    return ctx.getMavenKey()
+  case SSHMode:
+    return ctx.getSSHKey()
   default:
     if string(mode) == "" {
       return "", fmt.Errorf("unknown mode %s", ctx.Flags.Mode)
@@ -437,25 +640,16 @@ func (ctx *Context) getOpCtx() (*op.Context, error) {
     return nil, err
   }
 
-  output, err := op.GetVault(ctx.OpFunc, op.Query{
-    Context: op.Context{SessionToken: sessionToken},
-    Key: vaultName,
-  })
+  // the default vault is created on first use
+  vault, err := ctx.getVault(sessionToken, vaultName)
+  vaultUUID = vault.UUID
+  if errors.Is(err, op.ErrVaultNotFound) && vaultName == vaultNameDefault {
+    vaultUUID, err = ctx.createVault(vaultName)
+  }
   if err != nil {
     return nil, err
   }
 
-  vaultUUID = gjson.Get(output, "uuid").String()
-  if vaultUUID == "" {
-    if vaultName != vaultNameDefault {
-      return nil, fmt.Errorf("unable to get the uuid of vault named '%s'", vaultName)
-    }
-    vaultUUID, err = ctx.createVault(vaultName)
-    if err != nil {
-      return nil, err
-    }
-  }
-
   ctx.setVaultUUID(vaultUUID)
 
   ctx.opCtx = &op.Context{
@@ -476,7 +670,33 @@ func (ctx *Context) getVaultUUID() (string, error) {
     return ctx.opCtx.VaultUUID, nil
   }
 
-  return ctx.keystore.Get(vaultUUIDKey)
+  return ctx.keystore.Get(ctx.accountKey(vaultUUIDKey))
+}
+
+// listDocuments lists all documents in the configured vault
+// and returns a map of each document's title to its uuid.
+func (ctx *Context) listDocuments() (map[string]string, error) {
+  overviews, err := ctx.listDocumentOverviews()
+  if err != nil {
+    return nil, err
+  }
+
+  documents := map[string]string{}
+  for _, overview := range overviews {
+    documents[overview.Overview.Title] = overview.UUID
+  }
+  return documents, nil
+}
+
+// listDocumentOverviews lists the uuid, title and last modified
+// time of each document in the configured vault.
+func (ctx *Context) listDocumentOverviews() ([]op.Document, error) {
+  opCtx, err := ctx.getOpCtx()
+  if err != nil {
+    return nil, err
+  }
+
+  return op.ListDocuments(ctx.GetBaseContext(), ctx.OpFunc, *opCtx)
 }
 
 // parseJSONInputs unmarshals as json the provided scanned lines into ctx.inputs.
@@ -510,24 +730,37 @@ func (ctx *Context) parseServerURLInput(lines []string) error {
   return nil
 }
 
+// validateDockerInputs checks that the parsed json inputs of a docker store
+// include both a server url and a username, as docker credential helpers do.
+func (ctx *Context) validateDockerInputs() error {
+  if strings.TrimSpace(ctx.inputs[dockerServerURLKey]) == "" {
+    return fmt.Errorf(ErrMsgDockerMissingServerURL)
+  }
+  if strings.TrimSpace(ctx.inputs["Username"]) == "" {
+    return fmt.Errorf(ErrMsgDockerMissingUsername)
+  }
+  return nil
+}
+
 // setSessionToken sets the provided session token in context and in the encrypted keystore.
 func (ctx *Context) setSessionToken(sessionToken string) error {
   if ctx.opCtx == nil {
     ctx.opCtx = &op.Context{}
   }
   ctx.opCtx.SessionToken = sessionToken
+  ctx.sessions.set(ctx.GetAccount(), sessionToken)
 
-  err := ctx.keystore.Set(sessionTokenDateKey, time.Now().Format(timeFormat))
+  err := ctx.keystore.Set(ctx.accountKey(sessionTokenDateKey), time.Now().Format(timeFormat))
   if err != nil {
     return err
   }
-  return ctx.keystore.Set(sessionTokenValueKey, sessionToken)
+  return ctx.keystore.Set(ctx.accountKey(sessionTokenValueKey), sessionToken)
 }
 
 // setVaultName sets the provided vault name in context and in the encrypted keystore.
 func (ctx *Context) setVaultName(vaultName string) {
   ctx.vaultName = vaultName
-  ctx.keystore.Set(vaultNameKey, vaultName)
+  ctx.keystore.Set(ctx.accountKey(vaultNameKey), vaultName)
 }
 
 // setVaultUUID sets the provided vault uuid in context and in the encrypted keystore.
@@ -536,7 +769,52 @@ func (ctx *Context) setVaultUUID(vaultUUID string) {
     ctx.opCtx = &op.Context{}
   }
   ctx.opCtx.VaultUUID = vaultUUID
-  ctx.keystore.Set(vaultUUIDKey, vaultUUID)
+  ctx.keystore.Set(ctx.accountKey(vaultUUIDKey), vaultUUID)
+}
+
+
+// shouldScanStdin determines whether the provided command expects input
+// over stdin in the current mode. Generic modes only read stdin on store,
+// netrc mode is generated from other credentials so never reads stdin,
+// goauth get receives its url as an argument rather than over stdin, and
+// maven get renders every stored server so requires no input.
+func (ctx *Context) shouldScanStdin(cmdName string) bool {
+  mode := ctx.GetMode()
+  if mode == NetrcMode || ((mode == GoauthMode || mode == MavenMode) && cmdName == "get") {
+    return false
+  }
+  return mode.IsPredefined() || cmdName == "store"
+}
+
+// upsertDocument creates a document with the provided title and content in
+// the configured vault, or replaces the content of an existing document.
+func (ctx *Context) upsertDocument(title string, fileName string, content string) error {
+  opCtx, err := ctx.getOpCtx()
+  if err != nil {
+    return err
+  }
+
+  input := op.DocumentUpsert{
+    Query:    op.Query{Context: *opCtx, Key: title},
+    Content:  content,
+    FileName: fileName,
+    Title:    title,
+  }
+
+  // a document which was just looked up and not found is created directly,
+  // otherwise editing by title doubles as the existence check, so that
+  // storing either a new or an existing credential only launches op once
+  if !ctx.isMissingDocument(opCtx.VaultUUID, title) {
+    err = op.EditDocument(ctx.GetBaseContext(), ctx.OpFunc, input)
+    if !errors.Is(err, op.ErrNotFound) {
+      return err
+    }
+  }
+  if _, err := op.CreateDocument(ctx.GetBaseContext(), ctx.OpFunc, input); err != nil {
+    return err
+  }
+  ctx.setMissingDocument(opCtx.VaultUUID, title, false)
+  return nil
 }
 
 
@@ -545,20 +823,34 @@ func (ctx *Context) setVaultUUID(vaultUUID string) {
 // getDockerKey processes the parsed input from stdin into a url which will
 // be used as the title for the stored document in 1Password. The expected
 // input format for `get/erase` is a plain url, and the expected input
-// format for `store` is json with a top level key "ServerURL".
+// format for `store` is json with a top level key "ServerURL". The url is
+// canonicalized so that docker, helm and oras all share the same key for
+// a given registry.
 func (ctx *Context) getDockerKey() (string, error) {
   cmd := ctx.GetCmd()
   if cmd == nil {
     return "", fmt.Errorf("cannot get docker key: unable to determine how to read inputs without knowledge of what command was run")
   }
 
+  return canonicalRegistryURL(ctx.inputs[dockerServerURLKey])
+}
+
+// getLegacyKey returns the key which the parsed input was stored with before
+// docker registry urls were canonicalized, i.e. the server url as provided
+// without its user info, or an empty string in other modes. Documents stored
+// under legacy keys are still read and erased, but never written to.
+func (ctx *Context) getLegacyKey() string {
+  if ctx.GetMode() != DockerMode {
+    return ""
+  }
+
   URL, err := url.Parse(ctx.inputs[dockerServerURLKey])
   if err != nil {
-    return "", err
+    return ""
   }
 
   scrubURL(URL)
-  return URL.String(), nil
+  return fmt.Sprintf("%s:%s", string(DockerMode), URL.String())
 }
 
 // getGitKey processes the parsed input from stdin into a url which will
@@ -631,21 +923,105 @@ func (ctx *Context) scanStdinLines() ([]string, error) {
 
 // --- generic helpers ---
 
-// isValidGenericMode checks whether the mode does
-//  have as a prefix any of the predefined modes.
+// isValidGenericMode checks whether the mode does not have as a prefix
+// any of the legacy predefined modes, and does not collide with the name
+// or document titles of any other predefined mode.
 func isValidGenericMode(mode string) bool {
   if mode == "" || strings.Contains(mode, " ") || strings.Contains(mode, "\n") || strings.Contains(mode, "\t") {
     return false
   }
+  mode = strings.ToLower(mode)
+  for _, predefinedMode := range legacyPredefinedModes {
+    if strings.HasPrefix(mode, predefinedMode) {
+      return false
+    }
+  }
   for _, predefinedMode := range PredefinedModes {
-    if strings.HasPrefix(strings.ToLower(mode), predefinedMode) {
+    if mode == predefinedMode || strings.HasPrefix(mode, predefinedMode + ":") {
       return false
     }
   }
   return true
 }
 
+// canonicalRegistryURL normalizes the different ways a registry can be
+// referred to (bare hostnames, default ports, Docker Hub aliases, api
+// version paths) into a single url. Docker Hub is always returned as
+// "https://index.docker.io/v1/" for compatibility with the docker cli.
+func canonicalRegistryURL(rawurl string) (string, error) {
+  rawurl = strings.TrimSpace(rawurl)
+  if rawurl == "" {
+    return "", fmt.Errorf("cannot parse registry from empty server url")
+  }
+  if !strings.Contains(rawurl, "://") {
+    rawurl = fmt.Sprintf("https://%s", rawurl)
+  }
+
+  URL, err := url.Parse(rawurl)
+  if err != nil {
+    return "", err
+  }
+  if URL.Host == "" {
+    return "", fmt.Errorf("cannot parse registry host from server url %s", rawurl)
+  }
+
+  scrubURL(URL)
+  URL.Scheme = strings.ToLower(URL.Scheme)
+  URL.Host = strings.ToLower(URL.Host)
+  URL.RawQuery = ""
+  URL.Fragment = ""
+
+  port := URL.Port()
+  if (URL.Scheme == "https" && port == "443") || (URL.Scheme == "http" && port == "80") {
+    URL.Host = URL.Hostname()
+  }
+
+  for _, dockerHubHost := range dockerHubHosts {
+    if URL.Host == dockerHubHost {
+      return dockerHubServerURL, nil
+    }
+  }
+
+  path := strings.TrimSuffix(URL.Path, "/")
+  for _, apiVersion := range []string{"/v1", "/v2"} {
+    path = strings.TrimSuffix(path, apiVersion)
+  }
+  URL.Path = path
+  URL.RawPath = ""
+
+  return URL.String(), nil
+}
+
+// parseKeyValueContent parses stored key=value content
+// (e.g. a git credential) into a map of its fields.
+func parseKeyValueContent(content string) map[string]string {
+  ctx := &Context{inputs: map[string]string{}}
+  ctx.parseKeyValueInputs(strings.Split(content, "\n"))
+  return ctx.inputs
+}
+
 // scrubURL removes the User and Password fields from the provided url
 func scrubURL(URL *url.URL) {
   URL.User = nil
 }
+
+// forEachConcurrently calls fn with each index below n, from at
+// most maxConcurrentOpCalls goroutines, and waits for them to return.
+func forEachConcurrently(n int, fn func(i int)) {
+  indices := make(chan int)
+  var wg sync.WaitGroup
+  for worker := 0; worker < maxConcurrentOpCalls && worker < n; worker++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range indices {
+        fn(i)
+      }
+    }()
+  }
+  for i := 0; i < n; i++ {
+    indices <- i
+  }
+  close(indices)
+  wg.Wait()
+}
@@ -0,0 +1,270 @@
+package util
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/tlowerison/credential-1password/op"
+)
+
+const CredentialsFileName = ".credentials"
+
+// CredentialsFileNames are the file names searched for in each directory by
+// FindCredentialsFile, in order of precedence.
+var CredentialsFileNames = []string{
+  CredentialsFileName,
+  CredentialsFileName + ".yaml",
+  CredentialsFileName + ".yml",
+  CredentialsFileName + ".toml",
+}
+
+// Credential is a single entry of a .credentials file. The credential is
+// looked up either by key, the same key used in references (see Reference),
+// or by input, which would be passed to get over stdin in its mode. Generic
+// modes take neither. Fetched documents are exposed in docker builds as the
+// secret id, which defaults to $mode-credentials, and in `run --manifest` as
+// the env var, which is set to field (or the mode's secret value by default).
+// Optional credentials are skipped if they cannot be fetched and credentials
+// in a vault other than the configured one are looked up there by name.
+type Credential struct {
+  Mode     Mode   `yaml:"mode" toml:"mode"`
+  Key      string `yaml:"key,omitempty" toml:"key,omitempty"`
+  Input    string `yaml:"input,omitempty" toml:"input,omitempty"`
+  ID       string `yaml:"id,omitempty" toml:"id,omitempty"`
+  Env      string `yaml:"env,omitempty" toml:"env,omitempty"`
+  Field    string `yaml:"field,omitempty" toml:"field,omitempty"`
+  Vault    string `yaml:"vault,omitempty" toml:"vault,omitempty"`
+  Optional bool   `yaml:"optional,omitempty" toml:"optional,omitempty"`
+}
+
+// SecretID returns the id which the credential is exposed with in docker
+// builds, i.e. the secret is available at /run/secrets/$id. Unless set,
+// the id is $mode-credentials.
+func (credential Credential) SecretID() string {
+  if credential.ID != "" {
+    return credential.ID
+  }
+  return fmt.Sprintf("%s-credentials", string(credential.Mode))
+}
+
+// FindCredentialsFile searches for a .credentials file (or one of its yaml
+// or toml variants) starting in dir and moving up the file tree until
+// reaching home. If dir is not a descendant of home, only dir is checked.
+// Returns "" if no file is found.
+func FindCredentialsFile(dir string, home string) (string, error) {
+  dir, err := filepath.Abs(dir)
+  if err != nil {
+    return "", err
+  }
+
+  isDescendant := home != "" && (dir == home || strings.HasPrefix(dir, home + string(filepath.Separator)))
+  for {
+    for _, name := range CredentialsFileNames {
+      path := filepath.Join(dir, name)
+      if info, err := os.Stat(path); err == nil && !info.IsDir() {
+        return path, nil
+      } else if err != nil && !os.IsNotExist(err) {
+        return "", err
+      }
+    }
+
+    if !isDescendant || dir == home {
+      return "", nil
+    }
+    dir = filepath.Dir(dir)
+  }
+}
+
+// ReadCredentialsFile finds the nearest .credentials file from the current
+// directory, or reads path if provided, and parses it. Returns the path read
+// from, which is "" along with no credentials if no file was found.
+func ReadCredentialsFile(path string) (string, []Credential, error) {
+  if path == "" {
+    wd, err := os.Getwd()
+    if err != nil {
+      return "", nil, err
+    }
+    path, err = FindCredentialsFile(wd, os.Getenv("HOME"))
+    if err != nil || path == "" {
+      return "", []Credential{}, err
+    }
+  }
+
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return "", nil, err
+  }
+
+  credentials, err := ParseManifest(path, string(content))
+  if err != nil {
+    return "", nil, err
+  }
+  return path, credentials, nil
+}
+
+// ParseCredentials parses the contents of a legacy .credentials file: blocks
+// separated by blank lines, where the first line of each block is the
+// mode and any following lines are the input to get in that mode.
+func ParseCredentials(content string) ([]Credential, error) {
+  credentials := []Credential{}
+  for _, block := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+    lines := []string{}
+    for _, line := range strings.Split(block, "\n") {
+      if strings.TrimSpace(line) != "" {
+        lines = append(lines, strings.TrimSpace(line))
+      }
+    }
+    if len(lines) == 0 {
+      continue
+    }
+
+    mode := Mode(lines[0])
+    if !mode.Valid() {
+      return nil, fmt.Errorf("invalid mode %s in %s", lines[0], CredentialsFileName)
+    }
+
+    credentials = append(credentials, Credential{
+      Mode:  mode,
+      Input: strings.Join(lines[1:], "\n"),
+    })
+  }
+  return credentials, nil
+}
+
+// GetCredential gets the stored document for the provided credential, i.e.
+// what `get` would print in the credential's mode given its key or input.
+func (ctx *Context) GetCredential(credential Credential) (string, error) {
+  lookup, err := ctx.forkCredential(credential)
+  if err != nil {
+    return "", err
+  }
+  return lookup.getForkedDocument()
+}
+
+// GetCredentials gets every provided credential in parallel, see
+// forEachConcurrently, and returns the credentials which were found along
+// with their documents in the same order, or the first error encountered.
+// Optional credentials which cannot be fetched are left out.
+func (ctx *Context) GetCredentials(credentials []Credential) ([]Credential, []string, error) {
+  documents := make([]string, len(credentials))
+  errs := make([]error, len(credentials))
+
+  // resolve each credential's account, session and vault up front rather than
+  // concurrently, so that each account is signed into at most once
+  lookups := make([]*Context, len(credentials))
+  for i, credential := range credentials {
+    lookups[i], errs[i] = ctx.forkCredential(credential)
+    if errs[i] != nil && !credential.Optional {
+      return nil, nil, errs[i]
+    }
+  }
+
+  forEachConcurrently(len(credentials), func(i int) {
+    if errs[i] == nil {
+      documents[i], errs[i] = lookups[i].GetDocument()
+    }
+  })
+
+  // sign into accounts whose session expired one at a time, see getForkedDocument
+  for i, err := range errs {
+    if !op.ShouldClearSessionAndRetry(err) {
+      continue
+    }
+    if errs[i] = lookups[i].renewSession(); errs[i] == nil {
+      documents[i], errs[i] = lookups[i].GetDocument()
+    }
+  }
+
+  found := []Credential{}
+  foundDocuments := []string{}
+  for i, err := range errs {
+    if err != nil && credentials[i].Optional {
+      continue
+    }
+    if err != nil {
+      return nil, nil, fmt.Errorf("unable to get %s credentials: %s", string(credentials[i].Mode), err.Error())
+    }
+    found = append(found, credentials[i])
+    foundDocuments = append(foundDocuments, documents[i])
+  }
+  return found, foundDocuments, nil
+}
+
+// ResolveCredentialEnv gets the value of the credential's env var from its
+// fetched document, i.e. its field or the mode's secret value by default.
+func ResolveCredentialEnv(credential Credential, document string) (string, error) {
+  return Reference{Mode: credential.Mode, Key: credential.Key, Field: credential.Field}.field(document)
+}
+
+// forkCredential returns a fork of ctx for getting the provided credential,
+// with the session of the credential's own account and its vault resolved:
+// the credential's vault if set, otherwise its account's configured vault.
+func (ctx *Context) forkCredential(credential Credential) (*Context, error) {
+  var lookup *Context
+  var err error
+  if credential.Key != "" {
+    lookup, err = ctx.lookup(credential.Mode, credential.Key)
+    if err != nil {
+      return nil, err
+    }
+  } else {
+    lookup, err = ctx.fork(credential.Mode, io.NopCloser(strings.NewReader(credential.Input)))
+    if err != nil {
+      return nil, err
+    }
+    if err := lookup.ParseInput(); err != nil {
+      return nil, err
+    }
+  }
+
+  if credential.Vault == "" {
+    if _, err := lookup.getOpCtx(); err != nil {
+      return nil, err
+    }
+    return lookup, nil
+  }
+
+  vaultUUID, err := lookup.getVaultUUIDByName(credential.Vault)
+  if err != nil {
+    return nil, err
+  }
+  if vaultUUID == "" {
+    return nil, fmt.Errorf("unable to get the uuid of vault named '%s'", credential.Vault)
+  }
+  lookup.opCtx.VaultUUID = vaultUUID
+  lookup.vaultName = credential.Vault
+  return lookup, nil
+}
+
+// getForkedDocument gets the document of a fork, see GetDocument, signing into
+// the fork's account again and retrying if its session has expired, since
+// WithSessionRetry only signs into the account of the Context it was run with.
+func (ctx *Context) getForkedDocument() (string, error) {
+  document, err := ctx.GetDocument()
+  if !op.ShouldClearSessionAndRetry(err) {
+    return document, err
+  }
+  if err := ctx.renewSession(); err != nil {
+    return "", err
+  }
+  return ctx.GetDocument()
+}
+
+// getVaultUUIDByName gets the uuid of the vault with the provided name
+// without changing, or creating, the configured vault.
+func (ctx *Context) getVaultUUIDByName(vaultName string) (string, error) {
+  sessionToken, err := ctx.GetSessionToken()
+  if err != nil {
+    return "", err
+  }
+
+  vault, err := ctx.getVault(sessionToken, vaultName)
+  if err != nil {
+    return "", err
+  }
+
+  return vault.UUID, nil
+}
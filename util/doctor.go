@@ -0,0 +1,300 @@
+package util
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "time"
+
+  "github.com/tlowerison/credential-1password/op"
+)
+
+const (
+  DoctorFail = "fail"
+  DoctorOK   = "ok"
+  DoctorSkip = "skip"
+)
+
+// DoctorCheck is the result of a single environment check.
+// Hint describes how to fix a failed check.
+type DoctorCheck struct {
+  Name   string
+  Status string
+  Detail string
+  Hint   string
+}
+
+// String formats the check as a single line, followed by its hint if it failed.
+func (check DoctorCheck) String() string {
+  line := fmt.Sprintf("[%s] %s", check.Status, check.Name)
+  if check.Detail != "" {
+    line = fmt.Sprintf("%s: %s", line, check.Detail)
+  }
+  if check.Status == DoctorFail && check.Hint != "" {
+    line = fmt.Sprintf("%s\n       %s", line, check.Hint)
+  }
+  return line
+}
+
+// Doctor checks that everything credential-1password depends on is set up:
+// the op binary, the keystore, the stored session, the configured vault and
+// the git and docker configs which point at this helper. Checks which depend
+// on an earlier failed check are skipped. Never prompts to sign in.
+func (ctx *Context) Doctor() []DoctorCheck {
+  checks := []DoctorCheck{}
+
+  opCheck := ctx.checkOp()
+  checks = append(checks, opCheck)
+
+  keystoreCheck := ctx.checkKeystore()
+  checks = append(checks, keystoreCheck)
+
+  sessionToken := ""
+  sessionCheck := DoctorCheck{Name: "1Password session", Status: DoctorSkip}
+  if opCheck.Status == DoctorOK && keystoreCheck.Status == DoctorOK {
+    sessionToken, sessionCheck = ctx.checkSession()
+  }
+  checks = append(checks, sessionCheck)
+
+  vaultCheck := DoctorCheck{Name: "configured vault", Status: DoctorSkip}
+  if sessionCheck.Status == DoctorOK {
+    vaultCheck = ctx.checkVault(sessionToken)
+  }
+  checks = append(checks, vaultCheck)
+
+  checks = append(checks, checkGitConfig(), checkDockerConfig())
+  return checks
+}
+
+// checkOp checks that op is installed and is a version 1 release,
+// since credential-1password relies on op's version 1 commands.
+func (ctx *Context) checkOp() DoctorCheck {
+  check := DoctorCheck{Name: "op binary"}
+
+  version, err := op.Version(ctx.GetBaseContext(), ctx.OpFunc)
+  if err != nil {
+    check.Status = DoctorFail
+    check.Detail = strings.TrimSpace(err.Error())
+    check.Hint = "install op 1.x and make sure it is in PATH: https://support.1password.com/command-line-getting-started"
+    return check
+  }
+
+  version = strings.TrimSpace(version)
+  if !strings.HasPrefix(strings.TrimPrefix(version, "v"), "1.") {
+    check.Status = DoctorFail
+    check.Detail = fmt.Sprintf("version %s is not supported", version)
+    check.Hint = "credential-1password uses op 1.x commands, install op 1.x and make sure it is first in PATH"
+    return check
+  }
+
+  check.Status = DoctorOK
+  check.Detail = fmt.Sprintf("version %s", version)
+  return check
+}
+
+// checkKeystore checks that the OS keystore can be read from.
+func (ctx *Context) checkKeystore() DoctorCheck {
+  check := DoctorCheck{Name: "keystore", Detail: runtime.GOOS}
+  if _, err := ctx.keystore.Get(vaultNameKey); err != nil {
+    check.Status = DoctorFail
+    check.Detail = err.Error()
+    switch runtime.GOOS {
+    case "darwin":
+      check.Hint = "make sure the login keychain exists and is unlocked, e.g. with `security unlock-keychain`"
+    case "linux":
+      check.Hint = "make sure a Secret Service provider such as gnome-keyring is running and unlocked, e.g. check with `secret-tool search service credential-1password`"
+    default:
+      check.Hint = "only darwin and linux keystores are supported"
+    }
+    return check
+  }
+
+  check.Status = DoctorOK
+  return check
+}
+
+// checkSession checks that a session token is stored in the keystore,
+// that it has not expired and that op still accepts it.
+func (ctx *Context) checkSession() (string, DoctorCheck) {
+  check := DoctorCheck{Name: "1Password session", Status: DoctorFail}
+  signinHint := fmt.Sprintf("run any command which reads from 1Password, e.g. `%s list`, to sign in", ctx.GetName())
+
+  sessionTokenDate, err := ctx.keystore.Get(ctx.accountKey(sessionTokenDateKey))
+  if err != nil {
+    check.Detail = err.Error()
+    return "", check
+  }
+
+  date, err := time.Parse(timeFormat, sessionTokenDate)
+  if sessionTokenDate == "" || err != nil {
+    check.Detail = "not signed in"
+    check.Hint = signinHint
+    return "", check
+  }
+  if time.Since(date) >= sessionIdleTimeout {
+    check.Detail = fmt.Sprintf("session expired at %s", date.Add(sessionIdleTimeout).Format(time.RFC3339))
+    check.Hint = signinHint
+    return "", check
+  }
+
+  sessionToken, err := ctx.keystore.Get(ctx.accountKey(sessionTokenValueKey))
+  if err != nil || sessionToken == "" {
+    check.Detail = "not signed in"
+    check.Hint = signinHint
+    return "", check
+  }
+
+  // any other failure, e.g. a network error, is reported as is
+  // rather than mistaken for a valid or an expired session
+  _, err = op.GetAccount(ctx.GetBaseContext(), ctx.OpFunc, sessionToken)
+  if op.ShouldClearSessionAndRetry(err) {
+    check.Detail = "session token was rejected by op"
+    check.Hint = signinHint
+    return "", check
+  }
+  if err != nil {
+    check.Detail = strings.TrimSpace(err.Error())
+    check.Hint = "check that 1Password can be reached, e.g. with `op get account`"
+    return "", check
+  }
+
+  check.Status = DoctorOK
+  check.Detail = fmt.Sprintf("signed in at %s", date.Format(time.RFC3339))
+  return sessionToken, check
+}
+
+// checkVault checks that the configured vault exists and
+// that its uuid matches the one stored in the keystore.
+func (ctx *Context) checkVault(sessionToken string) DoctorCheck {
+  check := DoctorCheck{Name: "configured vault", Status: DoctorFail}
+
+  vaultName, err := ctx.keystore.Get(ctx.accountKey(vaultNameKey))
+  if err != nil {
+    check.Detail = err.Error()
+    return check
+  }
+  if vaultName == "" {
+    vaultName = vaultNameDefault
+  }
+  configHint := fmt.Sprintf("run `%s config vault NAME [--create]` to configure an existing vault or create a new one", ctx.GetName())
+
+  vault, err := ctx.getVault(sessionToken, vaultName)
+  vaultUUID := vault.UUID
+  if err != nil && !errors.Is(err, op.ErrVaultNotFound) {
+    check.Detail = strings.TrimSpace(err.Error())
+    return check
+  }
+  if err != nil {
+    check.Detail = fmt.Sprintf("vault '%s' does not exist", vaultName)
+    check.Hint = configHint
+    if vaultName == vaultNameDefault {
+      check.Status = DoctorOK
+      check.Detail = fmt.Sprintf("vault '%s' will be created on first use", vaultName)
+    }
+    return check
+  }
+
+  storedVaultUUID, err := ctx.keystore.Get(ctx.accountKey(vaultUUIDKey))
+  if err == nil && storedVaultUUID != "" && storedVaultUUID != vaultUUID {
+    check.Detail = fmt.Sprintf("vault '%s' has uuid %s but %s is configured", vaultName, vaultUUID, storedVaultUUID)
+    check.Hint = configHint
+    return check
+  }
+
+  check.Status = DoctorOK
+  check.Detail = fmt.Sprintf("vault '%s' (%s)", vaultName, vaultUUID)
+  return check
+}
+
+// checkGitConfig checks that git is configured to use git-credential-1password.
+func checkGitConfig() DoctorCheck {
+  check := DoctorCheck{Name: "git credential.helper"}
+
+  if _, err := exec.LookPath("git"); err != nil {
+    check.Status = DoctorSkip
+    check.Detail = "git is not installed"
+    return check
+  }
+
+  output, _ := exec.Command("git", "config", "--get-all", "credential.helper").Output()
+  helpers := strings.Fields(strings.TrimSpace(string(output)))
+  configured := false
+  for _, helper := range helpers {
+    if helper == "1password" || strings.HasSuffix(helper, "git-credential-1password") {
+      configured = true
+    }
+  }
+
+  check.Status = DoctorFail
+  if !configured {
+    check.Detail = fmt.Sprintf("credential.helper is %q", strings.Join(helpers, ","))
+    check.Hint = "run `git config --global credential.helper 1password`"
+    return check
+  }
+  if _, err := exec.LookPath("git-credential-1password"); err != nil {
+    check.Detail = "git-credential-1password is not in PATH"
+    check.Hint = "move git-credential-1password from the release archive into PATH"
+    return check
+  }
+
+  check.Status = DoctorOK
+  check.Detail = "1password"
+  return check
+}
+
+// checkDockerConfig checks that docker's config.json, if it exists,
+// sets credsStore to use docker-credential-1password.
+func checkDockerConfig() DoctorCheck {
+  check := DoctorCheck{Name: "docker credsStore"}
+
+  dir := os.Getenv("DOCKER_CONFIG")
+  if dir == "" {
+    home, err := os.UserHomeDir()
+    if err != nil {
+      check.Status = DoctorSkip
+      check.Detail = err.Error()
+      return check
+    }
+    dir = filepath.Join(home, ".docker")
+  }
+  path := filepath.Join(dir, "config.json")
+
+  content, err := os.ReadFile(path)
+  if os.IsNotExist(err) {
+    check.Status = DoctorSkip
+    check.Detail = fmt.Sprintf("%s does not exist", path)
+    return check
+  }
+
+  check.Status = DoctorFail
+  config := struct {
+    CredsStore string `json:"credsStore"`
+  }{}
+  if err == nil {
+    err = json.Unmarshal(content, &config)
+  }
+  if err != nil {
+    check.Detail = fmt.Sprintf("unable to read %s: %s", path, err.Error())
+    return check
+  }
+
+  if config.CredsStore != "1password" {
+    check.Detail = fmt.Sprintf("credsStore is %q in %s", config.CredsStore, path)
+    check.Hint = fmt.Sprintf("run `docker logout` and set \"credsStore\": \"1password\" in %s", path)
+    return check
+  }
+  if _, err := exec.LookPath("docker-credential-1password"); err != nil {
+    check.Detail = "docker-credential-1password is not in PATH"
+    check.Hint = "move docker-credential-1password from the release archive into PATH"
+    return check
+  }
+
+  check.Status = DoctorOK
+  check.Detail = "1password"
+  return check
+}
@@ -8,8 +8,10 @@ replace (
 )
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/spf13/cobra v1.1.3
-	github.com/tidwall/gjson v1.7.4
 	github.com/tlowerison/credential-1password/keystore v0.0.0-00010101000000-000000000000
 	github.com/tlowerison/credential-1password/op v0.0.0-00010101000000-000000000000
+	golang.org/x/crypto v0.0.0-20200323165209-0ec3e9974c59
+	gopkg.in/yaml.v2 v2.4.0
 )
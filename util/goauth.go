@@ -0,0 +1,123 @@
+package util
+
+import (
+  "encoding/base64"
+  "fmt"
+  "net/url"
+  "strings"
+)
+
+const goauthTokenKey = "token"
+
+// GetGoauth responds to the GOAUTH=command protocol for the provided url.
+// The go command first invokes GOAUTH commands without a url, in which case
+// nothing is returned so that credentials are only looked up once a server
+// actually requests them. Dedicated goauth tokens are preferred and sent as
+// Bearer tokens, otherwise git credentials for the same host are sent using
+// Basic auth. The longest matching url prefix wins in both cases.
+func (ctx *Context) GetGoauth(rawurl string) (string, error) {
+  if rawurl == "" {
+    return "", nil
+  }
+
+  URL, err := url.Parse(rawurl)
+  if err != nil {
+    return "", err
+  }
+  if URL.Scheme != "https" || URL.Host == "" {
+    return "", nil
+  }
+
+  documents, err := ctx.listDocuments()
+  if err != nil {
+    return "", err
+  }
+
+  prefixes := goauthPrefixes(URL)
+
+  for _, prefix := range prefixes {
+    uuid, ok := documents[fmt.Sprintf("%s:%s", string(GoauthMode), prefix)]
+    if !ok {
+      continue
+    }
+    content, err := ctx.getDocument(uuid)
+    if err != nil {
+      return "", err
+    }
+    inputs := parseKeyValueContent(content)
+    if token := inputs[goauthTokenKey]; token != "" {
+      return formatGoauthCredential(prefix, fmt.Sprintf("Bearer %s", token)), nil
+    }
+  }
+
+  for _, prefix := range prefixes {
+    uuid, ok := documents[fmt.Sprintf("%s:%s", string(GitMode), prefix)]
+    if !ok {
+      continue
+    }
+    content, err := ctx.getDocument(uuid)
+    if err != nil {
+      return "", err
+    }
+    inputs := parseKeyValueContent(content)
+    username, password := inputs["username"], inputs["password"]
+    if username != "" || password != "" {
+      basic := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
+      return formatGoauthCredential(prefix, fmt.Sprintf("Basic %s", basic)), nil
+    }
+  }
+
+  return "", nil
+}
+
+// getGoauthKey processes the parsed input from stdin into a url prefix which
+// will be used as the title for the stored document in 1Password. The expected
+// input format for any of `get/store/erase` is multiple lines of key=value
+// pairs including `url=...` and, for `store`, `token=...`.
+func (ctx *Context) getGoauthKey() (string, error) {
+  rawurl, hasRawurl := ctx.inputs["url"]
+  if !hasRawurl {
+    return "", fmt.Errorf("url is missing in credentials")
+  }
+  if !strings.Contains(rawurl, "://") {
+    rawurl = fmt.Sprintf("https://%s", rawurl)
+  }
+
+  URL, err := url.Parse(rawurl)
+  if err != nil {
+    return "", err
+  }
+  if URL.Scheme != "https" {
+    return "", fmt.Errorf("goauth only supports https urls")
+  }
+
+  scrubURL(URL)
+  URL.RawQuery = ""
+  URL.Fragment = ""
+  URL.Path = strings.TrimSuffix(URL.Path, "/")
+  URL.RawPath = ""
+  return URL.String(), nil
+}
+
+// formatGoauthCredential formats a single credential set of
+// the GOAUTH=command protocol with an Authorization header.
+func formatGoauthCredential(prefix string, authorization string) string {
+  return fmt.Sprintf("%s\n\nAuthorization: %s\n\n", prefix, authorization)
+}
+
+// goauthPrefixes returns each url prefix of URL from longest to shortest,
+// moving up the path hierarchy one segment at a time until only the host
+// remains, e.g. https://host/a/b, https://host/a, https://host.
+func goauthPrefixes(URL *url.URL) []string {
+  base := fmt.Sprintf("https://%s", URL.Host)
+  segments := strings.Split(strings.Trim(URL.Path, "/"), "/")
+
+  prefixes := []string{}
+  for i := len(segments); i > 0; i-- {
+    if segments[i-1] == "" {
+      continue
+    }
+    prefixes = append(prefixes, fmt.Sprintf("%s/%s", base, strings.Join(segments[:i], "/")))
+  }
+  return append(prefixes, base)
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "path/filepath"
+  "text/template"
+)
+
+// Inject renders the provided template, replacing each {{ cred "mode" "key" "field" }}
+// placeholder with the referenced credential. The field is optional and the key
+// should be empty for generic modes, e.g. {{ cred "npm" "" "_authToken" }}.
+// Each document is only fetched once no matter how often it's referenced.
+func (ctx *Context) Inject(name string, text string) (string, error) {
+  documents := map[Reference]string{}
+
+  cred := func(mode string, key string, field ...string) (string, error) {
+    if len(field) > 1 {
+      return "", fmt.Errorf("cred takes at most 3 arguments: mode, key and field")
+    }
+
+    ref := Reference{Mode: Mode(mode), Key: key}
+    if len(field) == 1 {
+      ref.Field = field[0]
+    }
+    if err := ref.Validate(); err != nil {
+      return "", err
+    }
+
+    document := Reference{Mode: ref.Mode, Key: ref.Key}
+    content, ok := documents[document]
+    if !ok {
+      var err error
+      content, err = ctx.getReferencedDocument(ref)
+      if err != nil {
+        return "", err
+      }
+      documents[document] = content
+    }
+    return ref.field(content)
+  }
+
+  tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{"cred": cred}).Parse(text)
+  if err != nil {
+    return "", err
+  }
+
+  var output bytes.Buffer
+  if err := tmpl.Execute(&output, nil); err != nil {
+    return "", err
+  }
+  return output.String(), nil
+}
+
+// WriteSecretFile writes content to path readable only by the current user.
+// The content is first written to a temporary file in the same directory
+// which is then renamed over path, so path never has broader permissions
+// or partially written content.
+func WriteSecretFile(path string, content string) error {
+  file, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s.*", filepath.Base(path)))
+  if err != nil {
+    return err
+  }
+  defer os.Remove(file.Name())
+
+  if err := file.Chmod(0600); err != nil {
+    file.Close()
+    return err
+  }
+  if _, err := file.WriteString(content); err != nil {
+    file.Close()
+    return err
+  }
+  if err := file.Close(); err != nil {
+    return err
+  }
+  return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+  "encoding/json"
+  "fmt"
+  "sort"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  "github.com/tlowerison/credential-1password/op"
+)
+
+const (
+  JSONListFormat  = "json"
+  TableListFormat = "table"
+)
+
+var ListFormats = []string{TableListFormat, JSONListFormat}
+
+// usernameFields maps each predefined mode which stores
+// a username to the document field it is stored in.
+var usernameFields = map[Mode]string{
+  DockerMode: "username",
+  GitMode:    "username",
+  MavenMode:  "username",
+}
+
+// ListEntry describes a single stored credential without its secret.
+type ListEntry struct {
+  Mode      Mode      `json:"mode"`
+  Key       string    `json:"key,omitempty"`
+  Username  string    `json:"username,omitempty"`
+  UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// List lists every credential stored in the configured vault, or only those
+// stored in mode if provided, sorted by mode and key. Documents are only read
+// for modes which store a username, and only the username is kept. Docker
+// documents under a legacy key are left out if the registry has a document
+// under its canonical key, see getLegacyKey.
+func (ctx *Context) List(mode Mode) ([]ListEntry, error) {
+  overviews, err := ctx.listDocumentOverviews()
+  if err != nil {
+    return nil, err
+  }
+
+  dockerKeys := map[string]bool{}
+  for _, overview := range overviews {
+    if entry := parseListEntry(overview); entry.Mode == DockerMode {
+      dockerKeys[entry.Key] = true
+    }
+  }
+
+  entries := []ListEntry{}
+  uuids := []string{}
+  for _, overview := range overviews {
+    entry := parseListEntry(overview)
+    if mode != "" && entry.Mode != mode {
+      continue
+    }
+    // a document stored under a legacy docker key is shadowed by its
+    // registry's canonical document, which get reads first
+    if entry.Mode == DockerMode {
+      if key, err := canonicalRegistryURL(entry.Key); err == nil && key != entry.Key && dockerKeys[key] {
+        continue
+      }
+    }
+    entries = append(entries, entry)
+    uuids = append(uuids, overview.UUID)
+  }
+
+  errs := make([]error, len(entries))
+  forEachConcurrently(len(entries), func(i int) {
+    field, ok := usernameFields[entries[i].Mode]
+    if !ok {
+      return
+    }
+    content, err := ctx.getDocument(uuids[i])
+    if err != nil {
+      errs[i] = err
+      return
+    }
+    fields, err := parseDocumentFields(entries[i].Mode, content)
+    if err != nil {
+      errs[i] = fmt.Errorf("unable to read %s:%s: %s", string(entries[i].Mode), entries[i].Key, err.Error())
+      return
+    }
+    entries[i].Username = fields[field]
+  })
+
+  for _, err := range errs {
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  sort.SliceStable(entries, func(i, j int) bool {
+    if entries[i].Mode != entries[j].Mode {
+      return entries[i].Mode < entries[j].Mode
+    }
+    return entries[i].Key < entries[j].Key
+  })
+  return entries, nil
+}
+
+// FormatList formats the provided entries as an aligned table or as json.
+func FormatList(entries []ListEntry, format string) (string, error) {
+  switch format {
+  case "", TableListFormat:
+    builder := &strings.Builder{}
+    w := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(w, "MODE\tKEY\tUSERNAME\tUPDATED")
+    for _, entry := range entries {
+      updatedAt := ""
+      if !entry.UpdatedAt.IsZero() {
+        updatedAt = entry.UpdatedAt.Local().Format("2006-01-02 15:04:05")
+      }
+      fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", string(entry.Mode), entry.Key, entry.Username, updatedAt)
+    }
+    w.Flush()
+    return builder.String(), nil
+  case JSONListFormat:
+    output, err := json.MarshalIndent(entries, "", "  ")
+    if err != nil {
+      return "", err
+    }
+    return string(output) + "\n", nil
+  default:
+    return "", fmt.Errorf("unknown list format %s, expected one of {%s}", format, strings.Join(ListFormats, ","))
+  }
+}
+
+// FormatDockerList formats the provided docker entries as the output of
+// the docker credential helper protocol's list command: a json object
+// mapping each server url to its username.
+func FormatDockerList(entries []ListEntry) (string, error) {
+  servers := map[string]string{}
+  for _, entry := range entries {
+    if entry.Mode == DockerMode {
+      servers[entry.Key] = entry.Username
+    }
+  }
+
+  output, err := json.Marshal(servers)
+  if err != nil {
+    return "", err
+  }
+  return string(output), nil
+}
+
+// parseListEntry derives a document's mode and key from its title,
+// which is "mode:key" for predefined modes and the mode otherwise.
+func parseListEntry(overview op.Document) ListEntry {
+  entry := ListEntry{Mode: Mode(overview.Overview.Title), UpdatedAt: overview.UpdatedAt}
+  if elements := strings.SplitN(overview.Overview.Title, ":", 2); len(elements) == 2 && Mode(elements[0]).IsPredefined() {
+    entry.Mode = Mode(elements[0])
+    entry.Key = elements[1]
+  }
+  return entry
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+  "encoding/json"
+  "fmt"
+  "time"
+)
+
+// missingDocumentsKey holds the documents which were recently looked up
+// and not found, as a json object of "<vault uuid>/<title>" to the time
+// of the lookup.
+const missingDocumentsKey = "missing-documents"
+
+// missingDocumentTTL is how long a document which wasn't found is assumed
+// to still be missing. It covers the get followed by a store of a single
+// login, e.g. git's fill then approve, or docker login's get then store.
+const missingDocumentTTL = 5 * time.Minute
+
+// isMissingDocument reports whether the document with the provided title
+// was looked up in vaultUUID and not found within missingDocumentTTL, in
+// which case storing it can create it without first trying to edit it.
+func (ctx *Context) isMissingDocument(vaultUUID string, title string) bool {
+  _, ok := ctx.getMissingDocuments()[missingDocumentKey(vaultUUID, title)]
+  return ok
+}
+
+// setMissingDocument records whether the document with the provided title
+// is missing from vaultUUID. Records are only an optimisation, so failing
+// to read or write them is ignored.
+func (ctx *Context) setMissingDocument(vaultUUID string, title string, missing bool) {
+  documents := ctx.getMissingDocuments()
+  key := missingDocumentKey(vaultUUID, title)
+  if _, ok := documents[key]; !ok && !missing {
+    return
+  }
+
+  if missing {
+    documents[key] = time.Now()
+  } else {
+    delete(documents, key)
+  }
+  if len(documents) == 0 {
+    ctx.keystore.Delete(ctx.accountKey(missingDocumentsKey))
+    return
+  }
+
+  value, err := json.Marshal(documents)
+  if err != nil {
+    return
+  }
+  ctx.keystore.Set(ctx.accountKey(missingDocumentsKey), string(value))
+}
+
+// getMissingDocuments returns the unexpired missing document records.
+func (ctx *Context) getMissingDocuments() map[string]time.Time {
+  documents := map[string]time.Time{}
+  value, err := ctx.keystore.Get(ctx.accountKey(missingDocumentsKey))
+  if err != nil || value == "" {
+    return documents
+  }
+  if err := json.Unmarshal([]byte(value), &documents); err != nil {
+    return map[string]time.Time{}
+  }
+
+  for key, lookedUpAt := range documents {
+    if age := time.Since(lookedUpAt); age < 0 || age >= missingDocumentTTL {
+      delete(documents, key)
+    }
+  }
+  return documents
+}
+
+func missingDocumentKey(vaultUUID string, title string) string {
+  return fmt.Sprintf("%s/%s", vaultUUID, title)
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+  "fmt"
+  "path/filepath"
+  "regexp"
+  "strings"
+
+  "github.com/BurntSushi/toml"
+  "gopkg.in/yaml.v2"
+)
+
+const (
+  LegacyManifestFormat = "legacy"
+  TOMLManifestFormat   = "toml"
+  YAMLManifestFormat   = "yaml"
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var secretIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Manifest is the structured format of a .credentials file, e.g.
+//
+//   credentials:
+//     - mode: git
+//       key: https://github.com
+//     - mode: npm
+//       id: npmrc
+//       vault: shared
+//       optional: true
+//
+// or the equivalent toml using [[credentials]] tables.
+type Manifest struct {
+  Credentials []Credential `yaml:"credentials" toml:"credentials"`
+}
+
+// ParseManifest parses and validates the contents of the .credentials
+// file at path, which may be yaml, toml or the legacy format of blocks
+// separated by blank lines. The format is determined by the file's
+// extension, falling back to the contents of an extensionless file.
+func ParseManifest(path string, content string) ([]Credential, error) {
+  var credentials []Credential
+  switch manifestFormat(path, content) {
+  case TOMLManifestFormat:
+    manifest := Manifest{}
+    metadata, err := toml.Decode(content, &manifest)
+    if err != nil {
+      return nil, fmt.Errorf("invalid %s: %s", filepath.Base(path), err.Error())
+    }
+    if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+      return nil, fmt.Errorf("invalid %s: unknown field %s", filepath.Base(path), undecoded[0].String())
+    }
+    credentials = manifest.Credentials
+  case YAMLManifestFormat:
+    manifest := Manifest{}
+    if err := yaml.UnmarshalStrict([]byte(content), &manifest); err != nil {
+      return nil, fmt.Errorf("invalid %s: %s", filepath.Base(path), err.Error())
+    }
+    credentials = manifest.Credentials
+  default:
+    var err error
+    credentials, err = ParseCredentials(content)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  if credentials == nil {
+    credentials = []Credential{}
+  }
+  if err := ValidateCredentials(credentials); err != nil {
+    return nil, fmt.Errorf("invalid %s: %s", filepath.Base(path), err.Error())
+  }
+  return credentials, nil
+}
+
+// ValidateCredentials validates each credential and checks
+// that no two credentials set the same environment variable.
+func ValidateCredentials(credentials []Credential) error {
+  envs := map[string]int{}
+  for i, credential := range credentials {
+    if err := credential.Validate(); err != nil {
+      return fmt.Errorf("credentials[%d]: %s", i, err.Error())
+    }
+    if credential.Env == "" {
+      continue
+    }
+    if j, ok := envs[credential.Env]; ok {
+      return fmt.Errorf("credentials[%d]: env %s is already set by credentials[%d]", i, credential.Env, j)
+    }
+    envs[credential.Env] = i
+  }
+  return nil
+}
+
+// Validate checks that the credential's mode can be fetched, that it is
+// looked up by either a key or an input if and only if its mode is
+// predefined, and that its secret id and env name are well formed.
+func (credential Credential) Validate() error {
+  mode := credential.Mode
+  if !mode.Valid() {
+    return fmt.Errorf("unknown mode %s", string(mode))
+  }
+  if mode == NetrcMode {
+    return fmt.Errorf("%s mode cannot be used in %s", string(NetrcMode), CredentialsFileName)
+  }
+
+  if credential.Key != "" && credential.Input != "" {
+    return fmt.Errorf("only one of key and input can be provided")
+  }
+  if mode.IsPredefined() && credential.Key == "" && credential.Input == "" {
+    return fmt.Errorf("missing key for %s mode", string(mode))
+  }
+  if !mode.IsPredefined() && (credential.Key != "" || credential.Input != "") {
+    return fmt.Errorf("%s is not a predefined mode and does not take a key", string(mode))
+  }
+
+  if credential.ID != "" && !secretIDRegexp.MatchString(credential.ID) {
+    return fmt.Errorf("invalid id %s: must only contain letters, digits, '.', '_' and '-'", credential.ID)
+  }
+  if credential.Env != "" && !envNameRegexp.MatchString(credential.Env) {
+    return fmt.Errorf("invalid env %s: must only contain letters, digits and '_' and not start with a digit", credential.Env)
+  }
+  if credential.Field != "" && credential.Env == "" {
+    return fmt.Errorf("field %s is only used with env", credential.Field)
+  }
+  return nil
+}
+
+// manifestFormat determines the format of a .credentials file from
+// its extension or, if it has none, from its first meaningful line.
+func manifestFormat(path string, content string) string {
+  switch strings.ToLower(filepath.Ext(path)) {
+  case ".toml":
+    return TOMLManifestFormat
+  case ".yaml", ".yml":
+    return YAMLManifestFormat
+  }
+
+  for _, line := range strings.Split(content, "\n") {
+    line = strings.TrimSpace(line)
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    if strings.HasPrefix(line, "[") {
+      return TOMLManifestFormat
+    }
+    if line == "---" || strings.HasPrefix(line, "credentials:") {
+      return YAMLManifestFormat
+    }
+    return LegacyManifestFormat
+  }
+  return LegacyManifestFormat
+}
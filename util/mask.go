@@ -0,0 +1,93 @@
+package util
+
+import (
+  "io"
+  "sort"
+  "strings"
+  "sync"
+)
+
+const ConcealedSecret = "<concealed by 1Password>"
+
+// maskedWriter replaces every occurrence of any secret written through
+// it with ConcealedSecret. Output which could be the start of a secret
+// is held back until enough has been written to tell, or until Close.
+type maskedWriter struct {
+  mu       sync.Mutex
+  w        io.Writer
+  buf      string
+  secrets  []string
+  replacer *strings.Replacer
+}
+
+// NewMaskedWriter wraps w so that none of the provided secrets are written
+// to it. Close must be called to flush any output which has been held back.
+func NewMaskedWriter(w io.Writer, secrets []string) io.WriteCloser {
+  nonEmpty := []string{}
+  for _, secret := range secrets {
+    if secret != "" {
+      nonEmpty = append(nonEmpty, secret)
+    }
+  }
+  // longer secrets take precedence when secrets overlap
+  sort.SliceStable(nonEmpty, func(i, j int) bool { return len(nonEmpty[i]) > len(nonEmpty[j]) })
+
+  oldnew := []string{}
+  for _, secret := range nonEmpty {
+    oldnew = append(oldnew, secret, ConcealedSecret)
+  }
+
+  return &maskedWriter{
+    w:        w,
+    secrets:  nonEmpty,
+    replacer: strings.NewReplacer(oldnew...),
+  }
+}
+
+func (mw *maskedWriter) Write(p []byte) (int, error) {
+  mw.mu.Lock()
+  defer mw.mu.Unlock()
+
+  if len(mw.secrets) == 0 {
+    return mw.w.Write(p)
+  }
+
+  masked := mw.replacer.Replace(mw.buf + string(p))
+  held := mw.partialSecretSuffix(masked)
+  mw.buf = masked[len(masked)-held:]
+
+  if _, err := io.WriteString(mw.w, masked[:len(masked)-held]); err != nil {
+    return 0, err
+  }
+  return len(p), nil
+}
+
+// Close flushes any output which was being held back.
+func (mw *maskedWriter) Close() error {
+  mw.mu.Lock()
+  defer mw.mu.Unlock()
+
+  buf := mw.buf
+  mw.buf = ""
+  _, err := io.WriteString(mw.w, buf)
+  return err
+}
+
+// partialSecretSuffix returns the length of the longest suffix
+// of s which is a proper prefix of any of the secrets.
+func (mw *maskedWriter) partialSecretSuffix(s string) int {
+  longest := 0
+  for _, secret := range mw.secrets {
+    n := len(secret) - 1
+    if n > len(s) {
+      n = len(s)
+    }
+    for ; n > longest; n-- {
+      if strings.HasPrefix(secret, s[len(s)-n:]) {
+        longest = n
+        break
+      }
+    }
+  }
+  return longest
+}
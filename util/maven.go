@@ -0,0 +1,135 @@
+package util
+
+import (
+  "bytes"
+  "embed"
+  "encoding/xml"
+  "fmt"
+  "sort"
+  "strings"
+  "text/template"
+)
+
+const (
+  MavenFormat  = "maven"
+  GradleFormat = "gradle"
+)
+
+var MavenFormats = []string{
+  MavenFormat,
+  GradleFormat,
+}
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// mavenTemplates maps each maven mode output format to its embedded template.
+var mavenTemplates = map[string]string{
+  MavenFormat:  "templates/settings.xml.tmpl",
+  GradleFormat: "templates/gradle.properties.tmpl",
+}
+
+// propertiesEscaper escapes the characters which are
+// significant in java .properties keys and values.
+var propertiesEscaper = strings.NewReplacer(
+  "\\", "\\\\",
+  "\n", "\\n",
+  "\r", "\\r",
+  "\t", "\\t",
+  "=", "\\=",
+  ":", "\\:",
+  "#", "\\#",
+  "!", "\\!",
+  " ", "\\ ",
+)
+
+// MavenServer is a single server entry of a maven settings.xml, which
+// gradle uses as the prefix of its "<id>Username"/"<id>Password" properties.
+type MavenServer struct {
+  ID       string
+  Username string
+  Password string
+}
+
+// GetMavenServers lists all maven servers stored in the configured vault, sorted by id.
+func (ctx *Context) GetMavenServers() ([]MavenServer, error) {
+  documents, err := ctx.listDocuments()
+  if err != nil {
+    return nil, err
+  }
+
+  prefix := fmt.Sprintf("%s:", string(MavenMode))
+  servers := []MavenServer{}
+  for title, uuid := range documents {
+    if !strings.HasPrefix(title, prefix) {
+      continue
+    }
+
+    content, err := ctx.getDocument(uuid)
+    if err != nil {
+      return nil, err
+    }
+
+    inputs := parseKeyValueContent(content)
+    servers = append(servers, MavenServer{
+      ID:       strings.TrimPrefix(title, prefix),
+      Username: inputs["username"],
+      Password: inputs["password"],
+    })
+  }
+
+  sort.SliceStable(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+  return servers, nil
+}
+
+// RenderMaven renders all stored maven servers with the embedded
+// template for the provided format, defaulting to a maven settings.xml.
+func (ctx *Context) RenderMaven(format string) (string, error) {
+  if format == "" {
+    format = MavenFormat
+  }
+
+  name, ok := mavenTemplates[format]
+  if !ok {
+    return "", fmt.Errorf("unknown format %s - expected one of {%s}", format, strings.Join(MavenFormats, ","))
+  }
+
+  servers, err := ctx.GetMavenServers()
+  if err != nil {
+    return "", err
+  }
+
+  tmpl, err := template.New(strings.TrimPrefix(name, "templates/")).Funcs(template.FuncMap{
+    "properties": propertiesEscaper.Replace,
+    "xml":        escapeXML,
+  }).ParseFS(templates, name)
+  if err != nil {
+    return "", err
+  }
+
+  var output bytes.Buffer
+  err = tmpl.Execute(&output, servers)
+  if err != nil {
+    return "", err
+  }
+  return output.String(), nil
+}
+
+// getMavenKey processes the parsed input from stdin into a server id which
+// will be used as the title for the stored document in 1Password. The expected
+// input format for any of `store/erase` is multiple lines of key=value pairs
+// including `id=...` and, for `store`, `username=...` and `password=...`.
+func (ctx *Context) getMavenKey() (string, error) {
+  id := strings.TrimSpace(ctx.inputs["id"])
+  if id == "" {
+    return "", fmt.Errorf("id is missing in credentials")
+  }
+  return id, nil
+}
+
+// escapeXML escapes text for use within an xml element.
+func escapeXML(text string) (string, error) {
+  var output bytes.Buffer
+  err := xml.EscapeText(&output, []byte(text))
+  return output.String(), err
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+  "errors"
+  "fmt"
+
+  "github.com/tlowerison/credential-1password/op"
+)
+
+// MigrateVault copies every document in the configured vault into the vault
+// named vaultName, creating it first if it does not exist and shouldCreate
+// is true. Each copy is read back and compared with the original, and only
+// once every copy is verified is the configured vault switched. If move is
+// true, the originals are then deleted from the previous vault. Nothing is
+// copied if two documents share a title, since copies are made by title.
+// Returns the number of migrated documents.
+func (ctx *Context) MigrateVault(vaultName string, shouldCreate bool, move bool) (int, error) {
+  opCtx, err := ctx.getOpCtx()
+  if err != nil {
+    return 0, err
+  }
+  source := *opCtx
+
+  sourceVaultName, err := ctx.GetVaultName()
+  if err != nil {
+    return 0, err
+  }
+
+  // documents are copied by title, so duplicates can't all be migrated
+  overviews, err := ctx.listDocumentOverviews()
+  if err != nil {
+    return 0, err
+  }
+  titles := map[string]bool{}
+  for _, overview := range overviews {
+    if titles[overview.Overview.Title] {
+      return 0, fmt.Errorf("unable to migrate to vault '%s', vault '%s' has more than one document titled %s", vaultName, sourceVaultName, overview.Overview.Title)
+    }
+    titles[overview.Overview.Title] = true
+  }
+
+  vault, err := ctx.getVault(source.SessionToken, vaultName)
+  vaultUUID := vault.UUID
+  if errors.Is(err, op.ErrVaultNotFound) && shouldCreate {
+    vaultUUID, err = ctx.createVault(vaultName)
+  }
+  if err != nil {
+    return 0, err
+  }
+  if vaultUUID == source.VaultUUID {
+    return 0, fmt.Errorf("vault '%s' is already the configured vault", vaultName)
+  }
+
+  archive, err := ctx.Export()
+  if err != nil {
+    return 0, err
+  }
+
+  target := ctx.inVault(vaultName, vaultUUID)
+  if err := target.Restore(archive); err != nil {
+    return 0, fmt.Errorf("unable to migrate to vault '%s', vault '%s' is still configured: %s", vaultName, sourceVaultName, err.Error())
+  }
+
+  for _, document := range archive.Documents {
+    content, err := target.getDocument(document.Title)
+    if err != nil || content != document.Content {
+      return 0, fmt.Errorf("unable to verify %s in vault '%s', vault '%s' is still configured", document.Title, vaultName, sourceVaultName)
+    }
+  }
+
+  ctx.setVaultName(vaultName)
+  ctx.setVaultUUID(vaultUUID)
+
+  if move {
+    for _, overview := range overviews {
+      err := op.DeleteDocument(ctx.GetBaseContext(), ctx.OpFunc, op.Query{Context: source, Key: overview.UUID})
+      if err != nil {
+        return 0, fmt.Errorf("migrated to vault '%s' but unable to delete %s from vault '%s': %s", vaultName, overview.Overview.Title, sourceVaultName, err.Error())
+      }
+    }
+  }
+
+  return len(archive.Documents), nil
+}
+
+// inVault returns a new Context which shares ctx's account, session
+// and keystore but reads from and writes to the provided vault.
+func (ctx *Context) inVault(vaultName string, vaultUUID string) *Context {
+  account := ctx.GetAccount()
+  return &Context{
+    Flags:         &Flags{},
+    account:       &account,
+    baseCtx:       ctx.baseCtx,
+    keystore:      ctx.keystore,
+    OpFunc:        ctx.OpFunc,
+    opCtx:         &op.Context{SessionToken: ctx.opCtx.SessionToken, VaultUUID: vaultUUID},
+    sessions:      ctx.sessions,
+    stdinDeadline: ctx.stdinDeadline,
+    vaultName:     vaultName,
+    vaults:        ctx.vaults,
+  }
+}
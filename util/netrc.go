@@ -0,0 +1,148 @@
+package util
+
+import (
+  "fmt"
+  "net"
+  "net/url"
+  "os"
+  "os/signal"
+  "sort"
+  "strings"
+  "syscall"
+)
+
+const ErrMsgNetrcReadOnly = "netrc mode is generated from git credentials and cannot be modified directly, use git mode instead"
+
+// NetrcEntry is a single machine entry of a netrc file.
+type NetrcEntry struct {
+  Machine  string
+  Login    string
+  Password string
+}
+
+// String formats the entry as a single netrc line.
+func (entry NetrcEntry) String() string {
+  return fmt.Sprintf("machine %s login %s password %s", entry.Machine, entry.Login, entry.Password)
+}
+
+// GetNetrc synthesizes a netrc file from every git credential
+// stored in the configured vault. Credentials whose fields
+// cannot be represented in netrc syntax are skipped.
+func (ctx *Context) GetNetrc() (string, error) {
+  entries, err := ctx.GetNetrcEntries()
+  if err != nil {
+    return "", err
+  }
+
+  lines := []string{}
+  for _, entry := range entries {
+    lines = append(lines, entry.String())
+  }
+  if len(lines) == 0 {
+    return "", nil
+  }
+  return strings.Join(lines, "\n") + "\n", nil
+}
+
+// GetNetrcEntries lists all git credentials stored in the configured
+// vault and converts each into a netrc machine entry, sorted by machine.
+func (ctx *Context) GetNetrcEntries() ([]NetrcEntry, error) {
+  documents, err := ctx.listDocuments()
+  if err != nil {
+    return nil, err
+  }
+
+  prefix := fmt.Sprintf("%s:", string(GitMode))
+  entries := []NetrcEntry{}
+  for title, uuid := range documents {
+    if !strings.HasPrefix(title, prefix) {
+      continue
+    }
+
+    content, err := ctx.getDocument(uuid)
+    if err != nil {
+      return nil, err
+    }
+
+    entry, ok := netrcEntryFromGitCredential(strings.TrimPrefix(title, prefix), content)
+    if ok {
+      entries = append(entries, entry)
+    }
+  }
+
+  sort.SliceStable(entries, func(i, j int) bool { return entries[i].Machine < entries[j].Machine })
+  return entries, nil
+}
+
+// ServeFIFO creates a named pipe at path, writes content to the
+// first reader which opens it and then removes the pipe so that
+// content never persists on disk. Fails if path already exists.
+func ServeFIFO(path string, content string) error {
+  if _, err := os.Lstat(path); err == nil {
+    return fmt.Errorf("cannot create fifo: %s already exists", path)
+  }
+
+  if err := syscall.Mkfifo(path, 0600); err != nil {
+    return err
+  }
+  defer os.Remove(path)
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+  defer signal.Stop(signals)
+
+  errs := make(chan error, 1)
+  go func() {
+    // opening a fifo for writing blocks until a reader opens it
+    fifo, err := os.OpenFile(path, os.O_WRONLY, 0)
+    if err != nil {
+      errs <- err
+      return
+    }
+    _, err = fifo.WriteString(content)
+    if closeErr := fifo.Close(); err == nil {
+      err = closeErr
+    }
+    errs <- err
+  }()
+
+  select {
+  case err := <-errs:
+    return err
+  case sig := <-signals:
+    return fmt.Errorf("stopped serving %s: received %v", path, sig)
+  }
+}
+
+// netrcEntryFromGitCredential parses the key=value content of a stored git
+// credential into a netrc entry. The machine is derived from the credential's
+// host, falling back to the document's key if no host was stored.
+func netrcEntryFromGitCredential(key string, content string) (NetrcEntry, bool) {
+  inputs := parseKeyValueContent(content)
+
+  host := inputs["host"]
+  if host == "" {
+    URL, err := url.Parse(key)
+    if err != nil {
+      return NetrcEntry{}, false
+    }
+    host = URL.Host
+  }
+
+  if hostname, _, err := net.SplitHostPort(host); err == nil {
+    host = hostname
+  }
+
+  entry := NetrcEntry{
+    Machine:  host,
+    Login:    inputs["username"],
+    Password: inputs["password"],
+  }
+
+  for _, field := range []string{entry.Machine, entry.Login, entry.Password} {
+    if field == "" || strings.ContainsAny(field, " \t\n\r") {
+      return NetrcEntry{}, false
+    }
+  }
+  return entry, true
+}
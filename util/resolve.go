@@ -0,0 +1,176 @@
+package util
+
+import (
+  "encoding/json"
+  "fmt"
+  "strings"
+)
+
+// Reference identifies a single secret value stored in 1Password, written as
+// "mode:key#field", e.g. "git:https://github.com#username". The key is the
+// same value which would be passed over stdin to get in that mode (a url
+// for git/docker/goauth, a server id for maven and a host for ssh), and is
+// omitted for generic modes. The field is optional and defaults to the
+// mode's secret value.
+type Reference struct {
+  Mode  Mode
+  Key   string
+  Field string
+}
+
+// defaultFields maps each predefined mode to the field holding its secret value.
+var defaultFields = map[Mode]string{
+  DockerMode: "secret",
+  GitMode:    "password",
+  GoauthMode: goauthTokenKey,
+  MavenMode:  "password",
+  SSHMode:    sshPrivateKeyKey,
+}
+
+// ParseReference parses a "mode:key#field" reference.
+func ParseReference(reference string) (Reference, error) {
+  ref := Reference{}
+
+  if i := strings.LastIndex(reference, "#"); i != -1 {
+    ref.Field = reference[i+1:]
+    reference = reference[:i]
+  }
+
+  elements := strings.SplitN(reference, ":", 2)
+  ref.Mode = Mode(elements[0])
+  if len(elements) == 2 {
+    ref.Key = elements[1]
+  }
+
+  if err := ref.Validate(); err != nil {
+    return Reference{}, err
+  }
+  return ref, nil
+}
+
+// String formats the reference as "mode:key#field".
+func (ref Reference) String() string {
+  reference := string(ref.Mode)
+  if ref.Key != "" {
+    reference = fmt.Sprintf("%s:%s", reference, ref.Key)
+  }
+  if ref.Field != "" {
+    reference = fmt.Sprintf("%s#%s", reference, ref.Field)
+  }
+  return reference
+}
+
+// Validate checks that the reference's mode can be referenced and that
+// a key is provided if and only if the mode is predefined.
+func (ref Reference) Validate() error {
+  if !ref.Mode.Valid() {
+    return fmt.Errorf("invalid reference %s: unknown mode %s", ref.String(), string(ref.Mode))
+  }
+  if ref.Mode == NetrcMode {
+    return fmt.Errorf("invalid reference %s: %s mode cannot be referenced", ref.String(), string(NetrcMode))
+  }
+  if ref.Mode.IsPredefined() && ref.Key == "" {
+    return fmt.Errorf("invalid reference %s: missing key", ref.String())
+  }
+  if !ref.Mode.IsPredefined() && ref.Key != "" {
+    return fmt.Errorf("invalid reference %s: %s is not a predefined mode and does not take a key", ref.String(), string(ref.Mode))
+  }
+  return nil
+}
+
+// Resolve fetches the document the reference points to from the configured
+// vault and returns the referenced field. Generic modes return the whole
+// document unless a field is provided, in which case the document is read
+// as key=value lines.
+func (ctx *Context) Resolve(ref Reference) (string, error) {
+  content, err := ctx.getReferencedDocument(ref)
+  if err != nil {
+    return "", err
+  }
+  return ref.field(content)
+}
+
+// field extracts the referenced field from the referenced document's content.
+func (ref Reference) field(content string) (string, error) {
+  field := ref.Field
+  if field == "" {
+    field = defaultFields[ref.Mode]
+  }
+  if field == "" {
+    return strings.TrimSuffix(content, "\n"), nil
+  }
+
+  fields, err := parseDocumentFields(ref.Mode, content)
+  if err != nil {
+    return "", fmt.Errorf("unable to resolve %s: %s", ref.String(), err.Error())
+  }
+
+  value, ok := fields[strings.ToLower(field)]
+  if !ok {
+    return "", fmt.Errorf("unable to resolve %s: no field %s", ref.String(), field)
+  }
+  return value, nil
+}
+
+// getReferencedDocument gets the content of the document the reference points to.
+func (ctx *Context) getReferencedDocument(ref Reference) (string, error) {
+  lookup, err := ctx.lookup(ref.Mode, ref.Key)
+  if err != nil {
+    return "", err
+  }
+
+  content, err := lookup.getForkedDocument()
+  if err != nil {
+    return "", fmt.Errorf("unable to resolve %s: %s", ref.String(), err.Error())
+  }
+  return content, nil
+}
+
+// lookup returns a new Context which shares ctx's session, vault and keystore
+// but has its inputs set as though key had been provided over stdin to get in
+// the provided mode, so that each mode's own key derivation can be reused.
+func (ctx *Context) lookup(mode Mode, key string) (*Context, error) {
+  lookup, err := ctx.fork(mode, ctx.stdin)
+  if err != nil {
+    return nil, err
+  }
+
+  lookup.input = "\n"
+  switch mode {
+  case DockerMode:
+    lookup.inputs[dockerServerURLKey] = key
+  case GitMode, GoauthMode:
+    lookup.inputs["url"] = key
+  case MavenMode:
+    lookup.inputs["id"] = key
+  case SSHMode:
+    lookup.inputs[sshHostKey] = key
+  }
+  return lookup, nil
+}
+
+// parseDocumentFields parses a stored document into a map of its lowercased
+// field names to values. Docker documents are json, ssh documents hold a
+// private key after their key=value lines, and all others are key=value lines.
+func parseDocumentFields(mode Mode, content string) (map[string]string, error) {
+  var inputs map[string]string
+  switch mode {
+  case DockerMode:
+    inputs = map[string]string{}
+    if err := json.Unmarshal([]byte(content), &inputs); err != nil {
+      return nil, err
+    }
+  case SSHMode:
+    ctx := &Context{inputs: map[string]string{}}
+    ctx.parseSSHInputs(strings.Split(content, "\n"))
+    inputs = ctx.inputs
+  default:
+    inputs = parseKeyValueContent(content)
+  }
+
+  fields := map[string]string{}
+  for field, value := range inputs {
+    fields[strings.ToLower(field)] = value
+  }
+  return fields, nil
+}
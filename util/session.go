@@ -0,0 +1,150 @@
+package util
+
+import (
+  "fmt"
+  "net"
+  "time"
+
+  "github.com/tlowerison/credential-1password/op"
+  "golang.org/x/crypto/ssh/agent"
+)
+
+const sshAgentSocketKey = "ssh-agent.socket"
+
+// Status describes the stored 1Password session and configured vault.
+type Status struct {
+  SignedIn   bool
+  Shorthand  string
+  Account    string
+  Vault      string
+  VaultUUID  string
+  SignedInAt time.Time
+  ExpiresAt  time.Time
+}
+
+// String formats the status as "key: value" lines.
+func (status Status) String() string {
+  lines := ""
+  if status.Shorthand != "" {
+    lines += fmt.Sprintf("account shorthand: %s\n", status.Shorthand)
+  }
+  if !status.SignedIn {
+    lines += "signed in: no\n"
+  } else {
+    lines += "signed in: yes\n"
+    if status.Account != "" {
+      lines += fmt.Sprintf("account: %s\n", status.Account)
+    }
+    age := time.Since(status.SignedInAt).Round(time.Second)
+    lines += fmt.Sprintf("token age: %s (signed in at %s)\n", age, status.SignedInAt.Format(time.RFC3339))
+    lines += fmt.Sprintf("expires in: %s if idle\n", time.Until(status.ExpiresAt).Round(time.Second))
+  }
+  lines += fmt.Sprintf("vault: %s", status.Vault)
+  if status.VaultUUID != "" {
+    lines += fmt.Sprintf(" (%s)", status.VaultUUID)
+  }
+  return lines + "\n"
+}
+
+// Status reads the stored session and configured vault of the current
+// account without requesting a signin. If a session is stored and has
+// not expired, the account it belongs to is looked up with op.
+func (ctx *Context) Status() (Status, error) {
+  status := Status{Shorthand: ctx.GetAccount()}
+
+  vaultName, err := ctx.keystore.Get(ctx.accountKey(vaultNameKey))
+  if err != nil {
+    return status, err
+  }
+  if vaultName == "" {
+    vaultName = vaultNameDefault
+  }
+  status.Vault = vaultName
+  status.VaultUUID, _ = ctx.keystore.Get(ctx.accountKey(vaultUUIDKey))
+
+  sessionTokenDate, _ := ctx.keystore.Get(ctx.accountKey(sessionTokenDateKey))
+  sessionToken, _ := ctx.keystore.Get(ctx.accountKey(sessionTokenValueKey))
+  date, err := time.Parse(timeFormat, sessionTokenDate)
+  if err != nil || sessionToken == "" || time.Since(date) >= sessionIdleTimeout {
+    return status, nil
+  }
+
+  account, err := op.GetAccount(ctx.GetBaseContext(), ctx.OpFunc, sessionToken)
+  if op.ShouldClearSessionAndRetry(err) {
+    return status, nil
+  }
+  if err != nil {
+    return status, err
+  }
+
+  status.SignedIn = true
+  status.SignedInAt = date
+  status.ExpiresAt = date.Add(sessionIdleTimeout)
+  status.Account = formatAccount(account)
+  return status, nil
+}
+
+// Logout signs out of the current account's stored session, deletes the
+// session and vault uuid from the keystore and removes every key from a
+// running ssh agent started by `ssh-agent`. Returns whether a running ssh
+// agent was notified.
+func (ctx *Context) Logout() (bool, error) {
+  sessionToken, _ := ctx.keystore.Get(ctx.accountKey(sessionTokenValueKey))
+  var signoutErr error
+  if sessionToken != "" {
+    signoutErr = op.Signout(ctx.GetBaseContext(), ctx.OpFunc, sessionToken)
+    // an already expired session is as good as signed out
+    if op.ShouldClearSessionAndRetry(signoutErr) {
+      signoutErr = nil
+    }
+  }
+
+  ctx.opCtx = &op.Context{}
+  for _, key := range []string{sessionTokenDateKey, sessionTokenValueKey, vaultUUIDKey} {
+    if err := ctx.keystore.Delete(ctx.accountKey(key)); err != nil {
+      return false, err
+    }
+  }
+
+  notified := ctx.notifySSHAgent()
+  return notified, signoutErr
+}
+
+// SetSSHAgentSocket stores the socket of a running ssh agent so
+// that it can be notified on logout, or clears it if path is empty.
+func (ctx *Context) SetSSHAgentSocket(path string) error {
+  if path == "" {
+    return ctx.keystore.Delete(ctx.accountKey(sshAgentSocketKey))
+  }
+  return ctx.keystore.Set(ctx.accountKey(sshAgentSocketKey), path)
+}
+
+// notifySSHAgent removes every key from the running ssh agent,
+// if any, through the ssh agent protocol in the same way as
+// `ssh-add -D`. Returns whether an agent was reached.
+func (ctx *Context) notifySSHAgent() bool {
+  socketPath, err := ctx.keystore.Get(ctx.accountKey(sshAgentSocketKey))
+  if err != nil || socketPath == "" {
+    return false
+  }
+
+  conn, err := net.Dial("unix", socketPath)
+  if err != nil {
+    ctx.keystore.Delete(ctx.accountKey(sshAgentSocketKey))
+    return false
+  }
+  defer conn.Close()
+
+  return agent.NewClient(conn).RemoveAll() == nil
+}
+
+// formatAccount formats an account as "name (domain)".
+func formatAccount(account op.Account) string {
+  if account.Domain == "" {
+    return account.Name
+  }
+  if account.Name == "" {
+    return account.Domain
+  }
+  return fmt.Sprintf("%s (%s)", account.Name, account.Domain)
+}
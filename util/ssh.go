@@ -0,0 +1,364 @@
+package util
+
+import (
+  "fmt"
+  "net"
+  "os"
+  "os/exec"
+  "os/signal"
+  "path"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "syscall"
+  "time"
+
+  "golang.org/x/crypto/ssh"
+  "golang.org/x/crypto/ssh/agent"
+)
+
+const sshConfirmKey = "confirm"
+const sshHostKey = "host"
+const sshLifetimeKey = "lifetime"
+const sshPrivateKeyKey = "private_key"
+const sshPEMPrefix = "-----BEGIN "
+
+// SSHKey is a private key stored in 1Password along with the host
+// pattern it is used for and the constraints it is loaded with.
+type SSHKey struct {
+  Pattern    string
+  PrivateKey interface{}
+  Confirm    bool
+  Lifetime   time.Duration
+}
+
+// AddedKey converts the key into an agent.AddedKey with its constraints applied.
+func (key SSHKey) AddedKey() agent.AddedKey {
+  return agent.AddedKey{
+    PrivateKey:       key.PrivateKey,
+    Comment:          key.Pattern,
+    LifetimeSecs:     uint32(key.Lifetime.Seconds()),
+    ConfirmBeforeUse: key.Confirm,
+  }
+}
+
+// GetSSHKeys loads every ssh key stored in the configured vault whose host
+// pattern matches any of the provided hosts, or all keys if no hosts are
+// provided. Keys are sorted from most to least specific host pattern.
+func (ctx *Context) GetSSHKeys(hosts []string) ([]SSHKey, error) {
+  documents, err := ctx.listDocuments()
+  if err != nil {
+    return nil, err
+  }
+
+  prefix := fmt.Sprintf("%s:", string(SSHMode))
+  keys := []SSHKey{}
+  for title, uuid := range documents {
+    if !strings.HasPrefix(title, prefix) {
+      continue
+    }
+
+    pattern := strings.TrimPrefix(title, prefix)
+    if len(hosts) != 0 && !matchesAnySSHHost(pattern, hosts) {
+      continue
+    }
+
+    content, err := ctx.getDocument(uuid)
+    if err != nil {
+      return nil, err
+    }
+
+    key, err := parseSSHKey(pattern, content)
+    if err != nil {
+      return nil, fmt.Errorf("unable to load ssh key for %s: %s", pattern, err.Error())
+    }
+    keys = append(keys, key)
+  }
+
+  sort.SliceStable(keys, func(i, j int) bool {
+    if len(keys[i].Pattern) != len(keys[j].Pattern) {
+      return len(keys[i].Pattern) > len(keys[j].Pattern)
+    }
+    return keys[i].Pattern < keys[j].Pattern
+  })
+  return keys, nil
+}
+
+// GetSSHPublicKey returns the public key, in authorized_keys format, of the
+// most specific stored ssh key whose host pattern matches the input host.
+func (ctx *Context) GetSSHPublicKey() (string, error) {
+  host := ctx.inputs[sshHostKey]
+  if host == "" {
+    return "", fmt.Errorf("host is missing in credentials")
+  }
+
+  keys, err := ctx.GetSSHKeys([]string{host})
+  if err != nil {
+    return "", err
+  }
+  if len(keys) == 0 {
+    return "", fmt.Errorf("no ssh key found for host %s", host)
+  }
+
+  signer, err := ssh.NewSignerFromKey(keys[0].PrivateKey)
+  if err != nil {
+    return "", err
+  }
+
+  authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+  return fmt.Sprintf("%s %s", authorizedKey, keys[0].Pattern), nil
+}
+
+// DefaultSSHAgentSocket returns the path of the ssh agent's unix
+// socket if none is provided, which is private to the current user.
+func DefaultSSHAgentSocket() string {
+  return filepath.Join(os.TempDir(), fmt.Sprintf("credential-1password-%d", os.Getuid()), "agent.sock")
+}
+
+// NewSSHAgent creates an in-memory ssh agent holding the provided keys.
+// If confirm is true, or a key was stored with confirm=true, every use
+// of that key must first be confirmed through $SSH_ASKPASS. If lifetime
+// is non-zero it is used for any key stored without its own lifetime.
+func NewSSHAgent(keys []SSHKey, confirm bool, lifetime time.Duration) (agent.ExtendedAgent, error) {
+  sshAgent := &sshAgent{
+    ExtendedAgent: agent.NewKeyring().(agent.ExtendedAgent),
+    confirm:       map[string]string{},
+    askpass:       confirmWithAskpass,
+  }
+
+  for _, key := range keys {
+    key.Confirm = key.Confirm || confirm
+    if key.Lifetime == 0 {
+      key.Lifetime = lifetime
+    }
+    if err := sshAgent.Add(key.AddedKey()); err != nil {
+      return nil, err
+    }
+  }
+  return sshAgent, nil
+}
+
+// ServeSSHAgent listens on a unix socket at socketPath and serves the provided
+// ssh agent to each connection until an interrupt or termination signal is
+// received, at which point the socket is removed.
+func ServeSSHAgent(socketPath string, sshAgent agent.Agent) error {
+  if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+    return err
+  }
+  if _, err := os.Lstat(socketPath); err == nil {
+    return fmt.Errorf("cannot create ssh agent socket: %s already exists", socketPath)
+  }
+
+  listener, err := net.Listen("unix", socketPath)
+  if err != nil {
+    return err
+  }
+  defer os.Remove(socketPath)
+  defer listener.Close()
+
+  if err := os.Chmod(socketPath, 0600); err != nil {
+    return err
+  }
+
+  signals := make(chan os.Signal, 1)
+  signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+  defer signal.Stop(signals)
+
+  errs := make(chan error, 1)
+  go func() {
+    for {
+      conn, err := listener.Accept()
+      if err != nil {
+        errs <- err
+        return
+      }
+      go func() {
+        defer conn.Close()
+        agent.ServeAgent(sshAgent, conn)
+      }()
+    }
+  }()
+
+  select {
+  case err := <-errs:
+    return err
+  case <-signals:
+    return nil
+  }
+}
+
+// --- ssh agent ---
+
+// sshAgent wraps an in-memory keyring, which ignores confirm constraints,
+// and requires confirmation before signing with keys added with one.
+type sshAgent struct {
+  agent.ExtendedAgent
+  mu      sync.Mutex
+  confirm map[string]string
+  askpass func(prompt string) error
+}
+
+func (a *sshAgent) Add(key agent.AddedKey) error {
+  err := a.ExtendedAgent.Add(key)
+  if err != nil || !key.ConfirmBeforeUse {
+    return err
+  }
+
+  signer, err := ssh.NewSignerFromKey(key.PrivateKey)
+  if err != nil {
+    return err
+  }
+
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  a.confirm[string(signer.PublicKey().Marshal())] = key.Comment
+  return nil
+}
+
+func (a *sshAgent) Remove(key ssh.PublicKey) error {
+  a.mu.Lock()
+  delete(a.confirm, string(key.Marshal()))
+  a.mu.Unlock()
+  return a.ExtendedAgent.Remove(key)
+}
+
+func (a *sshAgent) RemoveAll() error {
+  a.mu.Lock()
+  a.confirm = map[string]string{}
+  a.mu.Unlock()
+  return a.ExtendedAgent.RemoveAll()
+}
+
+func (a *sshAgent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+  return a.SignWithFlags(key, data, 0)
+}
+
+func (a *sshAgent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+  a.mu.Lock()
+  comment, shouldConfirm := a.confirm[string(key.Marshal())]
+  a.mu.Unlock()
+
+  if shouldConfirm {
+    prompt := fmt.Sprintf("Allow use of key %s (%s)?", comment, ssh.FingerprintSHA256(key))
+    if err := a.askpass(prompt); err != nil {
+      return nil, err
+    }
+  }
+  return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// --- ssh helpers ---
+
+// confirmWithAskpass asks the user to confirm the prompt with $SSH_ASKPASS,
+// in the same way as OpenSSH's ssh-agent, returning an error if denied.
+func confirmWithAskpass(prompt string) error {
+  askpass := os.Getenv("SSH_ASKPASS")
+  if askpass == "" {
+    return fmt.Errorf("agent refused operation: confirmation required but SSH_ASKPASS is not set")
+  }
+
+  cmd := exec.Command(askpass, prompt)
+  cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+  if err := cmd.Run(); err != nil {
+    return fmt.Errorf("agent refused operation: %s", err.Error())
+  }
+  return nil
+}
+
+// getSSHKey processes the parsed input from stdin into a host pattern which
+// will be used as the title for the stored document in 1Password. The expected
+// input format for any of `get/store/erase` is multiple lines of key=value
+// pairs including `host=...`, followed for `store` by a PEM encoded private key.
+func (ctx *Context) getSSHKey() (string, error) {
+  pattern := strings.TrimSpace(ctx.inputs[sshHostKey])
+  if pattern == "" {
+    return "", fmt.Errorf("host is missing in credentials")
+  }
+
+  if ctx.cmd != nil && strings.Split(ctx.cmd.Use, " ")[0] == "store" {
+    if _, err := parseSSHKey(pattern, ctx.input); err != nil {
+      return "", err
+    }
+  }
+  return pattern, nil
+}
+
+// parseSSHInputs processes the provided scanned lines as key=value pairs
+// into ctx.inputs until reaching a PEM block, which is stored as the
+// private key input along with every line following it.
+func (ctx *Context) parseSSHInputs(lines []string) error {
+  for i, line := range lines {
+    if strings.HasPrefix(line, sshPEMPrefix) {
+      ctx.inputs[sshPrivateKeyKey] = strings.Join(lines[i:], "\n")
+      return ctx.parseKeyValueInputs(lines[:i])
+    }
+  }
+  return ctx.parseKeyValueInputs(lines)
+}
+
+// matchesAnySSHHost reports whether the host pattern matches any of the hosts.
+func matchesAnySSHHost(pattern string, hosts []string) bool {
+  for _, host := range hosts {
+    if MatchSSHHost(pattern, host) {
+      return true
+    }
+  }
+  return false
+}
+
+// MatchSSHHost reports whether host matches the provided ssh_config style
+// pattern list: comma separated patterns supporting "*" and "?" wildcards,
+// where a pattern prefixed with "!" excludes any host it matches.
+func MatchSSHHost(pattern string, host string) bool {
+  host = strings.ToLower(host)
+  matched := false
+  for _, p := range strings.Split(strings.ToLower(pattern), ",") {
+    p = strings.TrimSpace(p)
+    negated := strings.HasPrefix(p, "!")
+    p = strings.TrimPrefix(p, "!")
+    if ok, err := path.Match(p, host); err != nil || !ok {
+      continue
+    }
+    if negated {
+      return false
+    }
+    matched = true
+  }
+  return matched
+}
+
+// parseSSHKey parses stored ssh key content into an SSHKey.
+func parseSSHKey(pattern string, content string) (SSHKey, error) {
+  ctx := &Context{inputs: map[string]string{}}
+  ctx.parseSSHInputs(strings.Split(content, "\n"))
+
+  pemBytes := []byte(strings.TrimSpace(ctx.inputs[sshPrivateKeyKey]) + "\n")
+  if len(pemBytes) == 1 {
+    return SSHKey{}, fmt.Errorf("private key is missing in credentials")
+  }
+
+  privateKey, err := ssh.ParseRawPrivateKey(pemBytes)
+  if err != nil {
+    return SSHKey{}, err
+  }
+
+  key := SSHKey{Pattern: pattern, PrivateKey: privateKey}
+
+  if confirm, ok := ctx.inputs[sshConfirmKey]; ok {
+    key.Confirm, err = strconv.ParseBool(confirm)
+    if err != nil {
+      return SSHKey{}, fmt.Errorf("invalid confirm %s: %s", confirm, err.Error())
+    }
+  }
+
+  if lifetime, ok := ctx.inputs[sshLifetimeKey]; ok {
+    key.Lifetime, err = time.ParseDuration(lifetime)
+    if err != nil {
+      return SSHKey{}, fmt.Errorf("invalid lifetime %s: %s", lifetime, err.Error())
+    }
+  }
+
+  return key, nil
+}
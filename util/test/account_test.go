@@ -0,0 +1,231 @@
+package test
+
+import (
+  "context"
+  "fmt"
+  "testing"
+
+  "github.com/spf13/cobra"
+  "github.com/stretchr/testify/require"
+  "github.com/tidwall/gjson"
+  "github.com/tidwall/sjson"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op/optest"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func testOpFuncWithAccounts(ctx context.Context, stdin string, args []string) (string, error) {
+  if args[0] == "signin" && len(args) == 3 {
+    return fmt.Sprintf("%s-token", args[1]), nil
+  }
+  if args[0] == "signin" {
+    return "default-token", nil
+  }
+  return "", fmt.Errorf("unexpected op call %v", args)
+}
+
+func newAccountContext(ks keystore.Keystore, mode string, input string) *util.Context {
+  ctx := util.NewContext(testOpFuncWithAccounts, ks, newTestStdin(input))
+  ctx.Flags.Mode = mode
+  ctx.SetCmd(&cobra.Command{Use: "get"})
+  return ctx
+}
+
+// pathKeystore stores every item in a single json document addressed by
+// dotted paths, like the darwin keystore.
+type pathKeystore struct {
+  data string
+}
+
+func (ks *pathKeystore) Delete(key string) (err error) {
+  ks.data, err = sjson.Delete(ks.data, key)
+  return err
+}
+
+func (ks *pathKeystore) Get(key string) (string, error) {
+  return gjson.Get(ks.data, key).String(), nil
+}
+
+func (ks *pathKeystore) Set(key string, value string) (err error) {
+  ks.data, err = sjson.Set(ks.data, key, value)
+  return err
+}
+
+func TestAccountKeys(t *testing.T) {
+  for _, defaultFirst := range []bool{true, false} {
+    ks := &pathKeystore{data: "{}"}
+    ctx := util.NewContext(testOpFunc, ks, newTestStdin(""))
+    setDefault := func() error { return ctx.SetDefaultAccount("work") }
+    setRoute := func() error { return ctx.SetAccountRoute("git", "personal") }
+    if defaultFirst {
+      require.Nil(t, setDefault())
+      require.Nil(t, setRoute())
+    } else {
+      require.Nil(t, setRoute())
+      require.Nil(t, setDefault())
+    }
+
+    // signing into the default account stores its session alongside both
+    sessionToken, err := newAccountContext(ks, "npm", "").Signin()
+    require.Nil(t, err)
+    require.Equal(t, "work-token", sessionToken)
+
+    account, err := ctx.GetDefaultAccount()
+    require.Nil(t, err)
+    require.Equal(t, "work", account)
+    routes, err := ctx.GetAccountRoutes()
+    require.Nil(t, err)
+    require.Equal(t, []util.AccountRoute{{Rule: "git", Account: "personal"}}, routes)
+  }
+}
+
+func TestAccountRoutes(t *testing.T) {
+  ks := keystore.NewMockKeystore(nil, map[string]string{})
+  ctx := util.NewContext(testOpFunc, ks, newTestStdin(""))
+  require.Nil(t, ctx.SetAccountRoute("git", "personal"))
+  require.Nil(t, ctx.SetAccountRoute("git:*.client.com", "client"))
+  require.Nil(t, ctx.SetAccountRoute("git:git.client.com", "client-internal"))
+  require.Nil(t, ctx.SetAccountRoute("npm", "client"))
+  require.Nil(t, ctx.SetAccountRoute("docker", "temporary"))
+  require.Nil(t, ctx.SetAccountRoute("docker", ""))
+
+  routes, err := ctx.GetAccountRoutes()
+  require.Nil(t, err)
+  require.Equal(t, []util.AccountRoute{
+    {Rule: "git:git.client.com", Account: "client-internal"},
+    {Rule: "git:*.client.com", Account: "client"},
+    {Rule: "git", Account: "personal"},
+    {Rule: "npm", Account: "client"},
+  }, routes)
+
+  require.NotNil(t, ctx.SetAccountRoute("git:", "client"))
+  require.NotNil(t, ctx.SetAccountRoute("gitlab", "client"))
+  require.NotNil(t, ctx.SetAccountRoute("npm", "not a shorthand"))
+  require.NotNil(t, ctx.SetDefaultAccount("not a shorthand"))
+
+  // a generic mode containing ":" has no host pattern
+  require.Nil(t, util.ValidateAccountRule("my:mode"))
+  require.Equal(t, util.Mode("my:mode"), util.AccountRoute{Rule: "my:mode"}.Mode())
+  require.Equal(t, "", util.AccountRoute{Rule: "my:mode"}.Pattern())
+}
+
+func TestContextGetAccount(t *testing.T) {
+  ks := keystore.NewMockKeystore(nil, map[string]string{})
+  ctx := util.NewContext(testOpFunc, ks, newTestStdin(""))
+  require.Nil(t, ctx.SetAccountRoute("git", "personal"))
+  require.Nil(t, ctx.SetAccountRoute("git:*.client.com", "client"))
+  require.Nil(t, ctx.SetAccountRoute("maven:nexus", "client"))
+
+  for input, account := range map[string]string{
+    "protocol=https\nhost=git.client.com\n": "client",
+    "url=https://github.com/org/repo\n":     "personal",
+  } {
+    ctx := newAccountContext(ks, "git", input)
+    require.Nil(t, ctx.ParseInput())
+    require.Equal(t, account, ctx.GetAccount())
+  }
+
+  // --account overrides routes
+  ctx = newAccountContext(ks, "git", "protocol=https\nhost=git.client.com\n")
+  ctx.Flags.Account = "other"
+  require.Nil(t, ctx.ParseInput())
+  require.Equal(t, "other", ctx.GetAccount())
+
+  // without a matching route the default account is used, if any
+  ctx = newAccountContext(ks, "npm", "")
+  require.Nil(t, ctx.ParseInput())
+  require.Equal(t, "", ctx.GetAccount())
+
+  require.Nil(t, ctx.SetDefaultAccount("work"))
+  require.Equal(t, "work", newAccountContext(ks, "npm", "").GetAccount())
+  require.Nil(t, ctx.SetDefaultAccount(""))
+  require.Equal(t, "", newAccountContext(ks, "npm", "").GetAccount())
+}
+
+func TestContextSessionPerAccount(t *testing.T) {
+  ks := keystore.NewMockKeystore(nil, map[string]string{})
+  ctx := util.NewContext(testOpFunc, ks, newTestStdin(""))
+  require.Nil(t, ctx.SetAccountRoute("git:*.client.com", "client"))
+
+  ctx = newAccountContext(ks, "git", "protocol=https\nhost=git.client.com\n")
+  require.Nil(t, ctx.ParseInput())
+  sessionToken, err := ctx.GetSessionToken()
+  require.Nil(t, err)
+  require.Equal(t, "client-token", sessionToken)
+
+  ctx = newAccountContext(ks, "git", "protocol=https\nhost=github.com\n")
+  require.Nil(t, ctx.ParseInput())
+  sessionToken, err = ctx.GetSessionToken()
+  require.Nil(t, err)
+  require.Equal(t, "default-token", sessionToken)
+
+  value, err := ks.Get("accounts.client.session-token.value")
+  require.Nil(t, err)
+  require.Equal(t, "client-token", value)
+  value, err = ks.Get("session-token.value")
+  require.Nil(t, err)
+  require.Equal(t, "default-token", value)
+
+  // the stored session is reused by the routed account
+  ctx = newAccountContext(ks, "git", "protocol=https\nhost=other.client.com\n")
+  ctx.OpFunc = testOpFuncWithErr("unexpected signin")
+  require.Nil(t, ctx.ParseInput())
+  sessionToken, err = ctx.GetSessionToken()
+  require.Nil(t, err)
+  require.Equal(t, "client-token", sessionToken)
+  require.Equal(t, "client", ctx.GetAccount())
+}
+
+func TestContextForkAccountRoutes(t *testing.T) {
+  sim := optest.NewSimulator()
+  sim.SetDocument(optest.DefaultAccount, "credential-1password", "git:https://gitlab.com", "protocol=https\nhost=gitlab.com\nusername=personal\npassword=personal-password\n")
+  sim.SetDocument("work", "credential-1password", "git:https://github.com", "protocol=https\nhost=github.com\nusername=work\npassword=work-password\n")
+
+  // the os keystores return empty values for missing keys
+  ks := keystore.NewMockKeystore(nil, map[string]string{})
+  for _, prefix := range []string{"", "accounts.work."} {
+    for _, key := range []string{"session-token.date", "session-token.value", "vault.name", "vault.uuid"} {
+      ks.Items[prefix + key] = ""
+    }
+  }
+  ctx := util.NewContext(sim.Op, ks, newTestStdin(""))
+  require.Nil(t, ctx.SetAccountRoute("git:github.com", "work"))
+
+  // each reference is read from the account its own route points to
+  for key, password := range map[string]string{
+    "https://github.com": "work-password",
+    "https://gitlab.com": "personal-password",
+  } {
+    value, err := ctx.Resolve(util.Reference{Mode: util.GitMode, Key: key})
+    require.Nil(t, err, key)
+    require.Equal(t, password, value, key)
+  }
+
+  output, err := ctx.Inject("template", `{{ cred "git" "https://github.com" }} {{ cred "git" "https://gitlab.com" "username" }}`)
+  require.Nil(t, err)
+  require.Equal(t, "work-password personal", output)
+
+  // the session of each account is shared by every credential routed to it
+  ctx = util.NewContext(sim.Op, ks, newTestStdin(""))
+  credentials, documents, err := ctx.GetCredentials([]util.Credential{
+    {Mode: util.GitMode, Key: "https://github.com"},
+    {Mode: util.GitMode, Key: "https://gitlab.com"},
+    {Mode: util.GitMode, Input: "protocol=https\nhost=github.com\n"},
+  })
+  require.Nil(t, err)
+  require.Len(t, credentials, 3)
+  require.Contains(t, documents[0], "password=work-password")
+  require.Contains(t, documents[1], "password=personal-password")
+  require.Contains(t, documents[2], "password=work-password")
+
+  // an expired session of a routed account is signed into again
+  sim.ExpireSessions()
+  ctx = util.NewContext(sim.Op, ks, newTestStdin(""))
+  _, documents, err = ctx.GetCredentials([]util.Credential{
+    {Mode: util.GitMode, Key: "https://github.com"},
+    {Mode: util.GitMode, Key: "https://gitlab.com"},
+  })
+  require.Nil(t, err)
+  require.Contains(t, documents[0], "password=work-password")
+  require.Contains(t, documents[1], "password=personal-password")
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestArchiveEncryption(t *testing.T) {
+  archive := util.Archive{
+    Vault:     "vault-name",
+    Documents: []util.ArchiveDocument{{Title: "npm", FileName: "npm-credentials", Content: "_authToken=npm-token\n"}},
+  }
+
+  data, err := util.EncryptArchive(archive, []byte("correct horse battery staple"))
+  require.Nil(t, err)
+  require.NotContains(t, string(data), "npm-token")
+
+  decrypted, err := util.DecryptArchive(data, []byte("correct horse battery staple"))
+  require.Nil(t, err)
+  require.Equal(t, archive.Documents, decrypted.Documents)
+
+  _, err = util.DecryptArchive(data, []byte("wrong passphrase"))
+  require.NotNil(t, err)
+
+  // scrypt parameters other than those written by EncryptArchive are rejected
+  // before deriving a key, which could otherwise exhaust memory
+  encrypted := map[string]interface{}{}
+  require.Nil(t, json.Unmarshal(data, &encrypted))
+  encrypted["n"] = 1 << 40
+  tampered, err := json.Marshal(encrypted)
+  require.Nil(t, err)
+  _, err = util.DecryptArchive(tampered, []byte("correct horse battery staple"))
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "unsupported archive scrypt parameters")
+
+  _, err = util.EncryptArchive(archive, []byte{})
+  require.NotNil(t, err)
+}
+
+func TestContextExportRestore(t *testing.T) {
+  documents := map[string]string{
+    "git:https://github.com": "protocol=https\nhost=github.com\nusername=git-username\npassword=git-password\n",
+    "npm":                    "_authToken=npm-token\n",
+  }
+  ctx := util.NewContext(testOpFuncWithStore(documents), newSignedInKeystore(), newTestStdin(""))
+
+  archive, err := ctx.Export()
+  require.Nil(t, err)
+  require.Equal(t, "vault-name", archive.Vault)
+  require.Equal(t, []util.ArchiveDocument{
+    {Title: "git:https://github.com", FileName: "git-credentials", Content: documents["git:https://github.com"]},
+    {Title: "npm", FileName: "npm-credentials", Content: documents["npm"]},
+  }, archive.Documents)
+
+  restored := map[string]string{"npm": "_authToken=old-token\n"}
+  ctx = util.NewContext(testOpFuncWithStore(restored), newSignedInKeystore(), newTestStdin(""))
+  require.Nil(t, ctx.Restore(archive))
+  require.Equal(t, documents, restored)
+
+  // failures other than missing documents are not mistaken for missing documents
+  created := false
+  ctx = util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    if args[0] == "create" {
+      created = true
+    }
+    return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 (429) Too Many Requests")
+  }, newSignedInKeystore(), newTestStdin(""))
+  err = ctx.Restore(archive)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "Too Many Requests")
+  require.False(t, created)
+}
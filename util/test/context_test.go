@@ -1,6 +1,8 @@
 package test
 
 import (
+  "context"
+  "errors"
   "fmt"
   "io"
   "os"
@@ -11,6 +13,8 @@ import (
   "github.com/spf13/cobra"
   "github.com/stretchr/testify/require"
   "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op"
+  "github.com/tlowerison/credential-1password/op/optest"
   "github.com/tlowerison/credential-1password/util"
 )
 
@@ -38,10 +42,50 @@ func (stdin *testStdin) Close() error {
 }
 
 
-func testOpFunc(stdin string, args []string) (string, error) {
+func testOpFunc(ctx context.Context, stdin string, args []string) (string, error) {
   return "", nil
 }
 
+func testOpFuncWithErr(errMsg string) func(ctx context.Context, stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    return "", fmt.Errorf(errMsg)
+  }
+}
+
+// testOpFuncWithDocuments simulates op over a vault containing
+// the provided documents, keyed by both title and uuid.
+func testOpFuncWithDocuments(documents map[string]string) func(ctx context.Context, stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    switch strings.Join(args[:2], " ") {
+    case "list documents":
+      list := []string{}
+      for title := range documents {
+        list = append(list, fmt.Sprintf(`{"uuid":%q,"overview":{"title":%q}}`, title, title))
+      }
+      return fmt.Sprintf("[%s]", strings.Join(list, ",")), nil
+    case "get document":
+      document, ok := documents[args[2]]
+      if !ok {
+        return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 %q doesn't seem to be an item", args[2])
+      }
+      return document, nil
+    default:
+      return "", fmt.Errorf("unexpected op call %v", args)
+    }
+  }
+}
+
+// newSignedInKeystore returns a mock keystore with a fresh session
+// token and vault uuid so that no signin is requested of op.
+func newSignedInKeystore() keystore.Keystore {
+  return keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "session-token",
+    "vault.name":          "vault-name",
+    "vault.uuid":          "vault-uuid",
+  })
+}
+
 func TestModeIsPredefined(t *testing.T) {
   require.True(t, util.DockerMode.IsPredefined())
   require.True(t, util.GitMode.IsPredefined())
@@ -64,6 +108,15 @@ func TestModeValid(t *testing.T) {
   require.False(t, util.Mode("git_").Valid())
   require.False(t, util.Mode("docker_").Valid())
   require.True(t, util.Mode("npm_").Valid())
+
+  // modes added since only reserve their own name and titles
+  require.True(t, util.Mode("ssh-keys").Valid())
+  require.True(t, util.Mode("maven-repo").Valid())
+  require.True(t, util.Mode("netrcfile").Valid())
+  require.True(t, util.Mode("goauth_token").Valid())
+  require.False(t, util.Mode("SSH").Valid())
+  require.False(t, util.Mode("ssh:github.com").Valid())
+  require.False(t, util.Mode("maven:central").Valid())
 }
 
 func TestNewContext(t *testing.T) {
@@ -81,6 +134,43 @@ func TestContextCmd(t *testing.T) {
   require.Equal(t, expCmd, cmd)
 }
 
+func TestContextStdin(t *testing.T) {
+  ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin("template\n"))
+  input, err := io.ReadAll(ctx.GetStdin())
+  require.Nil(t, err)
+  require.Equal(t, "template\n", string(input))
+}
+
+type testContextKey struct{}
+
+func TestContextBaseContext(t *testing.T) {
+  ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, map[string]string{}), newTestStdin(""))
+  require.NotNil(t, ctx.GetBaseContext())
+
+  // op calls are made with the base context, and signin is interactive
+  calls := []string{}
+  ctx.OpFunc = func(opCtx context.Context, stdin string, args []string) (string, error) {
+    if err := opCtx.Err(); err != nil {
+      return "", err
+    }
+    calls = append(calls, fmt.Sprintf("%s %v %v", args[0], opCtx.Value(testContextKey{}), op.IsInteractive(opCtx)))
+    return "session-token", nil
+  }
+  baseCtx, cancel := context.WithCancel(context.WithValue(context.Background(), testContextKey{}, "base"))
+  ctx.SetBaseContext(baseCtx)
+  sessionToken, err := ctx.GetSessionToken()
+  require.Nil(t, err)
+  require.Equal(t, "session-token", sessionToken)
+  require.Equal(t, []string{"signin base true"}, calls)
+
+  // cancelling the base context stops any further op calls
+  cancel()
+  ctx = util.NewContext(ctx.OpFunc, keystore.NewMockKeystore(nil, map[string]string{}), newTestStdin(""))
+  ctx.SetBaseContext(baseCtx)
+  _, err = ctx.GetSessionToken()
+  require.True(t, errors.Is(err, context.Canceled))
+}
+
 func TestContextInputGet(t *testing.T) {
   input := ""
   ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
@@ -309,4 +399,164 @@ func TestContextInputStore(t *testing.T) {
   err = ctx.ParseInput()
   require.NotNil(t, err)
   require.Equal(t, "invalid character 'h' looking for beginning of value", err.Error())
+
+  // docker-credential-1password store without a server url or username
+  for input, errMsg := range map[string]string{
+    `{"Username": "my-username", "Secret": "my-secret"}`:                  util.ErrMsgDockerMissingServerURL,
+    `{"ServerURL": "https://index.docker.io/v1/", "Secret": "my-secret"}`: util.ErrMsgDockerMissingUsername,
+    `{"ServerURL": "", "Username": "my-username", "Secret": "my-secret"}`: util.ErrMsgDockerMissingServerURL,
+  } {
+    ctx = util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
+    ctx.SetCmd(&cobra.Command{Use: "store"})
+    ctx.Flags.Mode = string(util.DockerMode)
+
+    err = ctx.ParseInput()
+    require.NotNil(t, err)
+    require.Equal(t, errMsg, err.Error())
+  }
+}
+
+func TestContextDockerKey(t *testing.T) {
+  cases := map[string]string{
+    "https://index.docker.io/v1/":    "docker:https://index.docker.io/v1/",
+    "index.docker.io":                "docker:https://index.docker.io/v1/",
+    "docker.io":                      "docker:https://index.docker.io/v1/",
+    "registry-1.docker.io":           "docker:https://index.docker.io/v1/",
+    "ghcr.io":                        "docker:https://ghcr.io",
+    "https://ghcr.io":                "docker:https://ghcr.io",
+    "https://ghcr.io/":               "docker:https://ghcr.io",
+    "https://ghcr.io:443/v2/":        "docker:https://ghcr.io",
+    "GHCR.io":                        "docker:https://ghcr.io",
+    "http://localhost:80":            "docker:http://localhost",
+    "localhost:5000":                 "docker:https://localhost:5000",
+    "https://user@registry.io/v1/":   "docker:https://registry.io",
+    "registry.io/charts":             "docker:https://registry.io/charts",
+  }
+
+  for input, expKey := range cases {
+    ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
+    ctx.SetCmd(&cobra.Command{Use: "get"})
+    ctx.Flags.Mode = string(util.DockerMode)
+
+    err := ctx.ParseInput()
+    require.Nil(t, err)
+
+    key, err := ctx.GetKey()
+    require.Nil(t, err, input)
+    require.Equal(t, expKey, key, input)
+  }
+
+  // store and get share the same key
+  input := "{\"ServerURL\": \"ghcr.io\", \"Username\": \"my-username\", \"Secret\": \"my-secret\"}"
+  ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
+  ctx.SetCmd(&cobra.Command{Use: "store"})
+  ctx.Flags.Mode = string(util.DockerMode)
+
+  err := ctx.ParseInput()
+  require.Nil(t, err)
+
+  key, err := ctx.GetKey()
+  require.Nil(t, err)
+  require.Equal(t, "docker:https://ghcr.io", key)
+}
+
+func TestContextDockerLegacyKey(t *testing.T) {
+  // credentials stored before registry urls were canonicalized
+  documents := map[string]string{
+    "docker:ghcr.io":                     `{"ServerURL":"ghcr.io","Username":"ghcr-username","Secret":"ghcr-secret"}`,
+    "docker:https://index.docker.io/v2/": `{"ServerURL":"https://index.docker.io/v2/","Username":"hub-username","Secret":"hub-secret"}`,
+    "docker:https://quay.io":             `{"ServerURL":"https://quay.io","Username":"quay-username","Secret":"quay-secret"}`,
+  }
+  opFunc := func(ctx context.Context, stdin string, args []string) (string, error) {
+    if strings.Join(args[:2], " ") == "delete document" {
+      if _, ok := documents[args[2]]; !ok {
+        return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 %q doesn't seem to be an item", args[2])
+      }
+      delete(documents, args[2])
+      return "", nil
+    }
+    return testOpFuncWithDocuments(documents)(ctx, stdin, args)
+  }
+  newDockerContext := func(cmd string, input string) *util.Context {
+    ctx := util.NewContext(opFunc, newSignedInKeystore(), newTestStdin(input))
+    ctx.SetCmd(&cobra.Command{Use: cmd})
+    ctx.Flags.Mode = string(util.DockerMode)
+    require.Nil(t, ctx.ParseInput())
+    return ctx
+  }
+
+  for input, expUsername := range map[string]string{
+    "ghcr.io":                     "ghcr-username",
+    "https://index.docker.io/v2/": "hub-username",
+    "https://quay.io":             "quay-username",
+    "quay.io":                     "quay-username",
+  } {
+    document, err := newDockerContext("get", input).GetDocument()
+    require.Nil(t, err, input)
+    require.Contains(t, document, expUsername, input)
+  }
+
+  // other spellings only find credentials stored under the canonical key
+  _, err := newDockerContext("get", "https://ghcr.io").GetDocument()
+  require.True(t, errors.Is(err, op.ErrNotFound))
+
+  document, err := util.NewContext(opFunc, newSignedInKeystore(), newTestStdin("")).GetCredential(util.Credential{Mode: util.DockerMode, Key: "ghcr.io"})
+  require.Nil(t, err)
+  require.Contains(t, document, "ghcr-secret")
+
+  // erase removes credentials stored under either key
+  require.Nil(t, newDockerContext("erase", "ghcr.io").DeleteDocument())
+  _, ok := documents["docker:ghcr.io"]
+  require.False(t, ok)
+  documents["docker:https://ghcr.io"] = `{"ServerURL":"https://ghcr.io"}`
+  documents["docker:ghcr.io"] = `{"ServerURL":"ghcr.io"}`
+  require.Nil(t, newDockerContext("erase", "ghcr.io").DeleteDocument())
+  require.Equal(t, 2, len(documents))
+  err = newDockerContext("erase", "ghcr.io").DeleteDocument()
+  require.True(t, errors.Is(err, op.ErrNotFound))
+}
+
+func TestContextDockerLegacyAndCanonicalKeys(t *testing.T) {
+  sim := optest.NewSimulator()
+  sim.SetDocument(optest.DefaultAccount, "credential-1password", "docker:ghcr.io", `{"ServerURL":"ghcr.io","Username":"old-username","Secret":"old-secret"}`)
+  // the os keystores return empty values for missing keys
+  ks := keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  "",
+    "session-token.value": "",
+    "vault.name":          "",
+    "vault.uuid":          "",
+  })
+  newDockerContext := func(cmd string, input string) *util.Context {
+    ctx := util.NewContext(sim.Op, ks, newTestStdin(input))
+    ctx.SetCmd(&cobra.Command{Use: cmd})
+    ctx.Flags.Mode = string(util.DockerMode)
+    require.Nil(t, ctx.ParseInput())
+    return ctx
+  }
+
+  // logging in again stores the credential under the canonical key
+  require.Nil(t, newDockerContext("store", `{"ServerURL":"ghcr.io","Username":"new-username","Secret":"new-secret"}`).Store())
+  _, ok := sim.Document(optest.DefaultAccount, "credential-1password", "docker:https://ghcr.io")
+  require.True(t, ok)
+
+  // which shadows the legacy document
+  entries, err := util.NewContext(sim.Op, ks, newTestStdin("")).List(util.DockerMode)
+  require.Nil(t, err)
+  require.Equal(t, 1, len(entries))
+  require.Equal(t, "https://ghcr.io", entries[0].Key)
+  require.Equal(t, "new-username", entries[0].Username)
+  output, err := util.FormatDockerList(entries)
+  require.Nil(t, err)
+  require.Equal(t, `{"https://ghcr.io":"new-username"}`, output)
+
+  document, err := newDockerContext("get", "ghcr.io").GetDocument()
+  require.Nil(t, err)
+  require.Contains(t, document, "new-secret")
+
+  // erase removes both
+  require.Nil(t, newDockerContext("erase", "ghcr.io").DeleteDocument())
+  _, ok = sim.Document(optest.DefaultAccount, "credential-1password", "docker:https://ghcr.io")
+  require.False(t, ok)
+  _, ok = sim.Document(optest.DefaultAccount, "credential-1password", "docker:ghcr.io")
+  require.False(t, ok)
 }
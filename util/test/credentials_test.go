@@ -0,0 +1,195 @@
+package test
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op/optest"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestParseCredentials(t *testing.T) {
+  credentials, err := util.ParseCredentials("git\nprotocol=https\nhost=github.com\n\n\n\nnpm\n\ndocker\nhttps://ghcr.io\n")
+  require.Nil(t, err)
+  require.Equal(t, []util.Credential{
+    {Mode: util.GitMode, Input: "protocol=https\nhost=github.com"},
+    {Mode: util.Mode("npm"), Input: ""},
+    {Mode: util.DockerMode, Input: "https://ghcr.io"},
+  }, credentials)
+  require.Equal(t, "git-credentials", credentials[0].SecretID())
+
+  credentials, err = util.ParseCredentials("")
+  require.Nil(t, err)
+  require.Equal(t, []util.Credential{}, credentials)
+
+  _, err = util.ParseCredentials("not a mode\nfoo=bar")
+  require.NotNil(t, err)
+}
+
+func TestFindCredentialsFile(t *testing.T) {
+  home := t.TempDir()
+  project := filepath.Join(home, "project")
+  nested := filepath.Join(project, "cmd", "app")
+  require.Nil(t, os.MkdirAll(nested, 0700))
+
+  path, err := util.FindCredentialsFile(nested, home)
+  require.Nil(t, err)
+  require.Equal(t, "", path)
+
+  require.Nil(t, os.WriteFile(filepath.Join(home, util.CredentialsFileName), []byte("npm\n"), 0600))
+  path, err = util.FindCredentialsFile(nested, home)
+  require.Nil(t, err)
+  require.Equal(t, filepath.Join(home, util.CredentialsFileName), path)
+
+  require.Nil(t, os.WriteFile(filepath.Join(project, util.CredentialsFileName), []byte("npm\n"), 0600))
+  path, err = util.FindCredentialsFile(nested, home)
+  require.Nil(t, err)
+  require.Equal(t, filepath.Join(project, util.CredentialsFileName), path)
+
+  require.Nil(t, os.WriteFile(filepath.Join(nested, util.CredentialsFileName + ".yaml"), []byte("credentials: []\n"), 0600))
+  path, err = util.FindCredentialsFile(nested, home)
+  require.Nil(t, err)
+  require.Equal(t, filepath.Join(nested, util.CredentialsFileName + ".yaml"), path)
+  require.Nil(t, os.Remove(path))
+
+  // directories outside of home only check themselves
+  path, err = util.FindCredentialsFile(nested, filepath.Join(home, "other"))
+  require.Nil(t, err)
+  require.Equal(t, "", path)
+}
+
+func TestContextGetCredentials(t *testing.T) {
+  documents := map[string]string{
+    "git:https://github.com": "protocol=https\nhost=github.com\nusername=my-username\npassword=my-password\n",
+    "npm":                    "_authToken=my-auth-token\n",
+  }
+  ctx := util.NewContext(testOpFuncWithDocuments(documents), newSignedInKeystore(), newTestStdin(""))
+
+  credentials := []util.Credential{
+    {Mode: util.GitMode, Input: "protocol=https\nhost=github.com"},
+    {Mode: util.GitMode, Key: "https://github.com", Env: "GITHUB_TOKEN"},
+    {Mode: util.Mode("npm")},
+  }
+  found, contents, err := ctx.GetCredentials(credentials)
+  require.Nil(t, err)
+  require.Equal(t, credentials, found)
+  require.Equal(t, []string{documents["git:https://github.com"], documents["git:https://github.com"], documents["npm"]}, contents)
+
+  value, err := util.ResolveCredentialEnv(found[1], contents[1])
+  require.Nil(t, err)
+  require.Equal(t, "my-password", value)
+
+  _, _, err = ctx.GetCredentials([]util.Credential{
+    {Mode: util.GitMode, Input: "protocol=https\nhost=gitlab.com"},
+  })
+  require.NotNil(t, err)
+
+  found, contents, err = ctx.GetCredentials([]util.Credential{
+    {Mode: util.GitMode, Key: "https://gitlab.com", Optional: true},
+    {Mode: util.Mode("npm")},
+  })
+  require.Nil(t, err)
+  require.Equal(t, []util.Credential{{Mode: util.Mode("npm")}}, found)
+  require.Equal(t, []string{documents["npm"]}, contents)
+}
+
+func TestContextGetCredentialsFromVault(t *testing.T) {
+  opFunc := testOpFuncWithDocuments(map[string]string{"npm": "_authToken=my-auth-token\n"})
+  sharedOpFunc := testOpFuncWithDocuments(map[string]string{"npm": "_authToken=shared-auth-token\n"})
+  ctx := util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    if strings.Join(args[:2], " ") == "get vault" {
+      if args[2] != "shared" {
+        return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 %q doesn't seem to be a vault in this account", args[2])
+      }
+      return `{"uuid":"shared-uuid","name":"shared"}`, nil
+    }
+    if args[len(args)-1] == "shared-uuid" {
+      return sharedOpFunc(ctx, stdin, args)
+    }
+    return opFunc(ctx, stdin, args)
+  }, newSignedInKeystore(), newTestStdin(""))
+
+  _, contents, err := ctx.GetCredentials([]util.Credential{
+    {Mode: util.Mode("npm")},
+    {Mode: util.Mode("npm"), Vault: "shared"},
+  })
+  require.Nil(t, err)
+  require.Equal(t, []string{"_authToken=my-auth-token\n", "_authToken=shared-auth-token\n"}, contents)
+
+  _, _, err = ctx.GetCredentials([]util.Credential{{Mode: util.Mode("npm"), Vault: "missing"}})
+  require.NotNil(t, err)
+
+  found, _, err := ctx.GetCredentials([]util.Credential{{Mode: util.Mode("npm"), Vault: "missing", Optional: true}})
+  require.Nil(t, err)
+  require.Equal(t, []util.Credential{}, found)
+}
+
+func TestContextGetCredentialsConcurrency(t *testing.T) {
+  documents := map[string]string{}
+  credentials := []util.Credential{}
+  for i := 0; i < 32; i++ {
+    mode := util.Mode(fmt.Sprintf("mode-%d", i))
+    documents[string(mode)] = "token"
+    credentials = append(credentials, util.Credential{Mode: mode})
+  }
+
+  opFunc := testOpFuncWithDocuments(documents)
+  mu := sync.Mutex{}
+  running, maxRunning := 0, 0
+  ctx := util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    mu.Lock()
+    running++
+    if running > maxRunning {
+      maxRunning = running
+    }
+    mu.Unlock()
+    defer func() {
+      mu.Lock()
+      running--
+      mu.Unlock()
+    }()
+    time.Sleep(time.Millisecond)
+    return opFunc(ctx, stdin, args)
+  }, newSignedInKeystore(), newTestStdin(""))
+
+  found, _, err := ctx.GetCredentials(credentials)
+  require.Nil(t, err)
+  require.Equal(t, credentials, found)
+  require.True(t, maxRunning > 1)
+  require.True(t, maxRunning <= 8)
+}
+
+func TestContextGetCredentialsFromVaultWithoutDefaultVault(t *testing.T) {
+  sim := optest.NewSimulator()
+  sim.SetDocument(optest.DefaultAccount, "shared", "npm", "_authToken=shared-auth-token\n")
+  // the os keystores return empty values for missing keys
+  ks := keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  "",
+    "session-token.value": "",
+    "vault.name":          "",
+    "vault.uuid":          "",
+  })
+
+  ctx := util.NewContext(sim.Op, ks, newTestStdin(""))
+  _, contents, err := ctx.GetCredentials([]util.Credential{{Mode: util.Mode("npm"), Vault: "shared"}})
+  require.Nil(t, err)
+  require.Equal(t, []string{"_authToken=shared-auth-token\n"}, contents)
+
+  // reading from another vault never creates the default vault
+  vaults := []string{}
+  for _, vault := range sim.Accounts[optest.DefaultAccount].Vaults {
+    vaults = append(vaults, vault.Name)
+  }
+  require.Equal(t, []string{optest.DefaultVault, "shared"}, vaults)
+  vaultUUID, err := ks.Get("vault.uuid")
+  require.Nil(t, err)
+  require.Equal(t, "", vaultUUID)
+}
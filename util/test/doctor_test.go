@@ -0,0 +1,120 @@
+package test
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func testOpFuncWithVersion(version string) func(ctx context.Context, stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    if args[len(args)-1] == "expired-token" {
+      return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 Invalid session token")
+    }
+    switch strings.Join(args, " ") {
+    case "--version":
+      return version, nil
+    case "get account --session session-token":
+      return `{"uuid":"account-uuid","name":"Account","domain":"account.1password.com"}`, nil
+    case "get vault vault-name --session session-token":
+      return `{"uuid":"vault-uuid","name":"vault-name"}`, nil
+    case "get account --session offline-token":
+      return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 dial tcp: lookup my.1password.com: no such host")
+    }
+    return "", fmt.Errorf("unexpected op call %v", args)
+  }
+}
+
+func doctorStatuses(checks []util.DoctorCheck) map[string]string {
+  statuses := map[string]string{}
+  for _, check := range checks {
+    statuses[check.Name] = check.Status
+  }
+  return statuses
+}
+
+func TestContextDoctor(t *testing.T) {
+  dockerConfig := t.TempDir()
+  os.Setenv("DOCKER_CONFIG", dockerConfig)
+  defer os.Setenv("DOCKER_CONFIG", "")
+
+  ctx := util.NewContext(testOpFuncWithVersion("1.12.4"), newSignedInKeystore(), newTestStdin(""))
+  checks := ctx.Doctor()
+  statuses := doctorStatuses(checks)
+  require.Equal(t, util.DoctorOK, statuses["op binary"])
+  require.Equal(t, util.DoctorOK, statuses["keystore"])
+  require.Equal(t, util.DoctorOK, statuses["1Password session"])
+  require.Equal(t, util.DoctorOK, statuses["configured vault"])
+  require.Equal(t, util.DoctorSkip, statuses["docker credsStore"])
+
+  require.Nil(t, os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(`{"credsStore":"desktop"}`), 0600))
+  checks = util.NewContext(testOpFuncWithVersion("1.12.4"), newSignedInKeystore(), newTestStdin("")).Doctor()
+  require.Equal(t, util.DoctorFail, doctorStatuses(checks)["docker credsStore"])
+  require.Contains(t, checks[len(checks)-1].String(), "credsStore\": \"1password\"")
+
+  // op 2.x is not supported, and checks depending on op are skipped
+  checks = util.NewContext(testOpFuncWithVersion("2.0.0"), newSignedInKeystore(), newTestStdin("")).Doctor()
+  statuses = doctorStatuses(checks)
+  require.Equal(t, util.DoctorFail, statuses["op binary"])
+  require.Equal(t, util.DoctorSkip, statuses["1Password session"])
+  require.Equal(t, util.DoctorSkip, statuses["configured vault"])
+
+  // expired and rejected sessions
+  for _, ks := range []keystore.Keystore{
+    keystore.NewMockKeystore(nil, map[string]string{"vault.name": "vault-name", "session-token.date": ""}),
+    keystore.NewMockKeystore(nil, map[string]string{
+      "session-token.date":  time.Now().Add(-time.Hour).Format(time.UnixDate),
+      "session-token.value": "session-token",
+      "vault.name":          "vault-name",
+    }),
+    keystore.NewMockKeystore(nil, map[string]string{
+      "session-token.date":  time.Now().Format(time.UnixDate),
+      "session-token.value": "expired-token",
+      "vault.name":          "vault-name",
+    }),
+  } {
+    checks = util.NewContext(testOpFuncWithVersion("1.12.4"), ks, newTestStdin("")).Doctor()
+    statuses = doctorStatuses(checks)
+    require.Equal(t, util.DoctorFail, statuses["1Password session"])
+    require.Equal(t, util.DoctorSkip, statuses["configured vault"])
+  }
+
+  // other op errors are reported rather than ignored
+  ks := keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "offline-token",
+    "vault.name":          "vault-name",
+  })
+  checks = util.NewContext(testOpFuncWithVersion("1.12.4"), ks, newTestStdin("")).Doctor()
+  statuses = doctorStatuses(checks)
+  require.Equal(t, util.DoctorFail, statuses["1Password session"])
+  require.Equal(t, util.DoctorSkip, statuses["configured vault"])
+  for _, check := range checks {
+    if check.Name == "1Password session" {
+      require.Contains(t, check.Detail, "no such host")
+    }
+  }
+
+  // keystore errors
+  ks = keystore.NewMockKeystore(fmt.Errorf("keystore is locked"), map[string]string{})
+  checks = util.NewContext(testOpFuncWithVersion("1.12.4"), ks, newTestStdin("")).Doctor()
+  require.Equal(t, util.DoctorFail, doctorStatuses(checks)["keystore"])
+
+  // vault uuid mismatch
+  ks = keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "session-token",
+    "vault.name":          "vault-name",
+    "vault.uuid":          "other-uuid",
+  })
+  checks = util.NewContext(testOpFuncWithVersion("1.12.4"), ks, newTestStdin("")).Doctor()
+  require.Equal(t, util.DoctorFail, doctorStatuses(checks)["configured vault"])
+}
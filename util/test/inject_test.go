@@ -0,0 +1,75 @@
+package test
+
+import (
+  "context"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestContextInject(t *testing.T) {
+  gets := 0
+  documents := map[string]string{
+    "git:https://github.com": "protocol=https\nhost=github.com\nusername=my-username\npassword=my-password\n",
+    "npm":                    "_authToken=my-auth-token\n",
+  }
+  opFunc := testOpFuncWithDocuments(documents)
+  ctx := util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    if strings.Join(args[:2], " ") == "get document" {
+      gets++
+    }
+    return opFunc(ctx, stdin, args)
+  }, newSignedInKeystore(), newTestStdin(""))
+
+  output, err := ctx.Inject("config.tmpl", `github:
+  user: {{ cred "git" "https://github.com" "username" }}
+  token: {{ cred "git" "https://github.com" }}
+  again: {{ cred "git" "https://github.com" | printf "%q" }}
+npm: {{ cred "npm" "" "_authToken" }}
+`)
+  require.Nil(t, err)
+  require.Equal(t, `github:
+  user: my-username
+  token: my-password
+  again: "my-password"
+npm: my-auth-token
+`, output)
+  require.Equal(t, 2, gets)
+
+  _, err = ctx.Inject("config.tmpl", `{{ cred "npm" "registry" }}`)
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "npm is not a predefined mode and does not take a key")
+
+  _, err = ctx.Inject("config.tmpl", `{{ cred "git" "https://gitlab.com" }}`)
+  require.NotNil(t, err)
+
+  _, err = ctx.Inject("config.tmpl", `{{ cred "git" "https://github.com" "username" "password" }}`)
+  require.NotNil(t, err)
+
+  _, err = ctx.Inject("config.tmpl", `{{ cred "git" `)
+  require.NotNil(t, err)
+}
+
+func TestWriteSecretFile(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "config.yaml")
+  require.Nil(t, os.WriteFile(path, []byte("old"), 0644))
+
+  require.Nil(t, util.WriteSecretFile(path, "token: my-password\n"))
+
+  data, err := os.ReadFile(path)
+  require.Nil(t, err)
+  require.Equal(t, "token: my-password\n", string(data))
+
+  info, err := os.Stat(path)
+  require.Nil(t, err)
+  require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+  entries, err := os.ReadDir(dir)
+  require.Nil(t, err)
+  require.Equal(t, 1, len(entries))
+}
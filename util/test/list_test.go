@@ -0,0 +1,105 @@
+package test
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestContextList(t *testing.T) {
+  documents := map[string]string{
+    "docker:https://ghcr.io":   `{"ServerURL":"https://ghcr.io","Username":"docker-username","Secret":"docker-secret"}`,
+    "git:https://github.com":   "protocol=https\nhost=github.com\nusername=git-username\npassword=git-password\n",
+    "goauth:https://go.dev/x":  "url=https://go.dev/x\ntoken=goauth-token\n",
+    "npm":                      "_authToken=npm-token\n",
+  }
+  updatedAt := time.Date(2021, 4, 29, 14, 42, 46, 0, time.UTC)
+  getDocuments := testOpFuncWithDocuments(documents)
+  ctx := util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    if strings.Join(args[:2], " ") != "list documents" {
+      return getDocuments(ctx, stdin, args)
+    }
+    list := []string{}
+    for title := range documents {
+      list = append(list, fmt.Sprintf(`{"uuid":%q,"updatedAt":%q,"overview":{"title":%q}}`, title, updatedAt.Format(time.RFC3339), title))
+    }
+    return fmt.Sprintf("[%s]", strings.Join(list, ",")), nil
+  }, newSignedInKeystore(), newTestStdin(""))
+
+  entries, err := ctx.List("")
+  require.Nil(t, err)
+  require.Equal(t, []util.ListEntry{
+    {Mode: util.DockerMode, Key: "https://ghcr.io", Username: "docker-username", UpdatedAt: updatedAt},
+    {Mode: util.GitMode, Key: "https://github.com", Username: "git-username", UpdatedAt: updatedAt},
+    {Mode: util.GoauthMode, Key: "https://go.dev/x", UpdatedAt: updatedAt},
+    {Mode: util.Mode("npm"), UpdatedAt: updatedAt},
+  }, entries)
+
+  output, err := util.FormatList(entries, util.JSONListFormat)
+  require.Nil(t, err)
+  for _, secret := range []string{"docker-secret", "git-password", "goauth-token", "npm-token"} {
+    require.NotContains(t, output, secret)
+  }
+
+  output, err = util.FormatList(entries, util.TableListFormat)
+  require.Nil(t, err)
+  require.Equal(t, 5, strings.Count(output, "\n"))
+  require.True(t, strings.HasPrefix(output, "MODE    KEY"))
+
+  _, err = util.FormatList(entries, "yaml")
+  require.NotNil(t, err)
+
+  entries, err = ctx.List(util.DockerMode)
+  require.Nil(t, err)
+  require.Len(t, entries, 1)
+
+  output, err = util.FormatDockerList(entries)
+  require.Nil(t, err)
+  require.Equal(t, `{"https://ghcr.io":"docker-username"}`, output)
+}
+
+func TestContextListConcurrency(t *testing.T) {
+  documents := map[string]string{}
+  for i := 0; i < 50; i++ {
+    documents[fmt.Sprintf("git:https://git-%d.example.com", i)] = fmt.Sprintf("username=git-username-%d\n", i)
+  }
+  getDocuments := testOpFuncWithDocuments(documents)
+
+  // documents are read by a bounded number of workers rather than all at once
+  var mu sync.Mutex
+  running, maxRunning := 0, 0
+  ctx := util.NewContext(func(ctx context.Context, stdin string, args []string) (string, error) {
+    if strings.Join(args[:2], " ") == "list documents" {
+      list := []string{}
+      for title := range documents {
+        list = append(list, fmt.Sprintf(`{"uuid":%q,"overview":{"title":%q}}`, title, title))
+      }
+      return fmt.Sprintf("[%s]", strings.Join(list, ",")), nil
+    }
+    mu.Lock()
+    running++
+    if running > maxRunning {
+      maxRunning = running
+    }
+    mu.Unlock()
+    time.Sleep(5 * time.Millisecond)
+    mu.Lock()
+    running--
+    mu.Unlock()
+    return getDocuments(ctx, stdin, args)
+  }, newSignedInKeystore(), newTestStdin(""))
+
+  entries, err := ctx.List(util.GitMode)
+  require.Nil(t, err)
+  require.Len(t, entries, 50)
+  for _, entry := range entries {
+    require.Equal(t, fmt.Sprintf("git-username-%s", strings.TrimSuffix(strings.TrimPrefix(entry.Key, "https://git-"), ".example.com")), entry.Username)
+  }
+  require.LessOrEqual(t, maxRunning, 8)
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestParseManifestYAML(t *testing.T) {
+  content := `credentials:
+  - mode: git
+    key: https://github.com
+    env: GITHUB_TOKEN
+  - mode: npm
+    id: npmrc
+    vault: shared
+    optional: true
+`
+  expected := []util.Credential{
+    {Mode: util.GitMode, Key: "https://github.com", Env: "GITHUB_TOKEN"},
+    {Mode: util.Mode("npm"), ID: "npmrc", Vault: "shared", Optional: true},
+  }
+
+  credentials, err := util.ParseManifest(".credentials.yaml", content)
+  require.Nil(t, err)
+  require.Equal(t, expected, credentials)
+  require.Equal(t, "git-credentials", credentials[0].SecretID())
+  require.Equal(t, "npmrc", credentials[1].SecretID())
+
+  // extensionless files are detected by content
+  credentials, err = util.ParseManifest(".credentials", content)
+  require.Nil(t, err)
+  require.Equal(t, expected, credentials)
+
+  _, err = util.ParseManifest(".credentials.yaml", "credentials:\n  - mode: npm\n    vualt: shared\n")
+  require.NotNil(t, err)
+}
+
+func TestParseManifestTOML(t *testing.T) {
+  content := `[[credentials]]
+mode = "ssh"
+key = "github.com"
+field = "private_key"
+env = "SSH_PRIVATE_KEY"
+
+[[credentials]]
+mode = "git"
+input = """
+protocol=https
+host=github.com"""
+`
+  expected := []util.Credential{
+    {Mode: util.SSHMode, Key: "github.com", Field: "private_key", Env: "SSH_PRIVATE_KEY"},
+    {Mode: util.GitMode, Input: "protocol=https\nhost=github.com"},
+  }
+
+  credentials, err := util.ParseManifest(".credentials.toml", content)
+  require.Nil(t, err)
+  require.Equal(t, expected, credentials)
+
+  credentials, err = util.ParseManifest(".credentials", content)
+  require.Nil(t, err)
+  require.Equal(t, expected, credentials)
+
+  _, err = util.ParseManifest(".credentials.toml", "[[credentials]]\nmode = \"npm\"\nvualt = \"shared\"\n")
+  require.NotNil(t, err)
+}
+
+func TestParseManifestLegacy(t *testing.T) {
+  credentials, err := util.ParseManifest(".credentials", "git\nprotocol=https\nhost=github.com\n\nnpm\n")
+  require.Nil(t, err)
+  require.Equal(t, []util.Credential{
+    {Mode: util.GitMode, Input: "protocol=https\nhost=github.com"},
+    {Mode: util.Mode("npm")},
+  }, credentials)
+}
+
+func TestValidateCredentials(t *testing.T) {
+  require.Nil(t, util.ValidateCredentials([]util.Credential{
+    {Mode: util.DockerMode, Key: "ghcr.io", ID: "docker.config", Env: "GHCR_TOKEN"},
+    {Mode: util.Mode("npm"), Env: "NPM_TOKEN", Field: "_authToken"},
+  }))
+
+  invalid := [][]util.Credential{
+    {{Mode: util.Mode("np m")}},
+    {{Mode: util.NetrcMode}},
+    {{Mode: util.GitMode}},
+    {{Mode: util.GitMode, Key: "https://github.com", Input: "host=github.com"}},
+    {{Mode: util.Mode("npm"), Key: "registry.npmjs.org"}},
+    {{Mode: util.Mode("npm"), ID: "../npmrc"}},
+    {{Mode: util.Mode("npm"), Env: "1NPM_TOKEN"}},
+    {{Mode: util.Mode("npm"), Field: "_authToken"}},
+    {{Mode: util.Mode("npm"), Env: "TOKEN"}, {Mode: util.Mode("pypi"), Env: "TOKEN"}},
+  }
+  for _, credentials := range invalid {
+    require.NotNil(t, util.ValidateCredentials(credentials), "%v", credentials)
+  }
+}
@@ -0,0 +1,32 @@
+package test
+
+import (
+  "bytes"
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestMaskedWriter(t *testing.T) {
+  var output bytes.Buffer
+  writer := util.NewMaskedWriter(&output, []string{"my-secret", "", "my-secret-token"})
+
+  for _, chunk := range []string{"token: my-sec", "ret-token\n", "secret: my-", "secret\n", "not a secret: my-", "se"} {
+    n, err := writer.Write([]byte(chunk))
+    require.Nil(t, err)
+    require.Equal(t, len(chunk), n)
+  }
+
+  // a partial secret is held back until more is written or the writer is closed
+  require.Equal(t, "token: <concealed by 1Password>\nsecret: <concealed by 1Password>\nnot a secret: ", output.String())
+
+  require.Nil(t, writer.Close())
+  require.Equal(t, "token: <concealed by 1Password>\nsecret: <concealed by 1Password>\nnot a secret: my-se", output.String())
+
+  // no secrets
+  output.Reset()
+  writer = util.NewMaskedWriter(&output, []string{})
+  writer.Write([]byte("my-secret"))
+  require.Equal(t, "my-secret", output.String())
+}
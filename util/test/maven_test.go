@@ -0,0 +1,84 @@
+package test
+
+import (
+  "testing"
+
+  "github.com/spf13/cobra"
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestContextRenderMaven(t *testing.T) {
+  documents := map[string]string{
+    "maven:nexus":          "id=nexus\nusername=my-username\npassword=p<a>ss&word\n",
+    "maven:github":         "id=github\nusername=my-username\npassword=my token=1\n",
+    "git:https://github.com": "protocol=https\nhost=github.com\nusername=my-username\npassword=my-password\n",
+  }
+  ctx := util.NewContext(testOpFuncWithDocuments(documents), newSignedInKeystore(), newTestStdin(""))
+
+  output, err := ctx.RenderMaven("")
+  require.Nil(t, err)
+  require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0"
+          xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+          xsi:schemaLocation="http://maven.apache.org/SETTINGS/1.0.0 https://maven.apache.org/xsd/settings-1.0.0.xsd">
+  <servers>
+    <server>
+      <id>github</id>
+      <username>my-username</username>
+      <password>my token=1</password>
+    </server>
+    <server>
+      <id>nexus</id>
+      <username>my-username</username>
+      <password>p&lt;a&gt;ss&amp;word</password>
+    </server>
+  </servers>
+</settings>
+`, output)
+
+  output, err = ctx.RenderMaven(util.GradleFormat)
+  require.Nil(t, err)
+  require.Equal(t, `githubUsername=my-username
+githubPassword=my\ token\=1
+nexusUsername=my-username
+nexusPassword=p<a>ss&word
+`, output)
+
+  _, err = ctx.RenderMaven("ivy")
+  require.NotNil(t, err)
+  require.Equal(t, "unknown format ivy - expected one of {maven,gradle}", err.Error())
+
+  // no stored servers
+  ctx = util.NewContext(testOpFuncWithDocuments(map[string]string{}), newSignedInKeystore(), newTestStdin(""))
+  output, err = ctx.RenderMaven(util.GradleFormat)
+  require.Nil(t, err)
+  require.Equal(t, "", output)
+}
+
+func TestContextMavenKey(t *testing.T) {
+  input := "id=nexus\nusername=my-username\npassword=my-password"
+  ctx := util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
+  ctx.SetCmd(&cobra.Command{Use: "store"})
+  ctx.Flags.Mode = string(util.MavenMode)
+
+  err := ctx.ParseInput()
+  require.Nil(t, err)
+
+  key, err := ctx.GetKey()
+  require.Nil(t, err)
+  require.Equal(t, "maven:nexus", key)
+
+  input = "username=my-username\npassword=my-password"
+  ctx = util.NewContext(testOpFunc, keystore.NewMockKeystore(nil, nil), newTestStdin(input))
+  ctx.SetCmd(&cobra.Command{Use: "store"})
+  ctx.Flags.Mode = string(util.MavenMode)
+
+  err = ctx.ParseInput()
+  require.Nil(t, err)
+
+  _, err = ctx.GetKey()
+  require.NotNil(t, err)
+  require.Equal(t, "id is missing in credentials", err.Error())
+}
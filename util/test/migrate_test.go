@@ -0,0 +1,166 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op"
+  "github.com/tlowerison/credential-1password/op/optest"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// testOpFuncWithVaults simulates op with documents stored per vault uuid,
+// where vaults are named after their uuid with the "-uuid" suffix removed.
+func testOpFuncWithVaults(vaults map[string]map[string]string) func(ctx context.Context, stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    vaultUUID := ""
+    for i, arg := range args {
+      if arg == "--vault" {
+        vaultUUID = args[i+1]
+      }
+    }
+
+    switch strings.Join(args[:2], " ") {
+    case "get vault":
+      if _, ok := vaults[args[2] + "-uuid"]; !ok {
+        return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 %q doesn't seem to be a vault in this account", args[2])
+      }
+      return fmt.Sprintf(`{"uuid":"%s-uuid","name":%q}`, args[2], args[2]), nil
+    case "create vault":
+      vaults[args[2] + "-uuid"] = map[string]string{}
+      return fmt.Sprintf(`{"uuid":"%s-uuid"}`, args[2]), nil
+    case "delete document":
+      delete(vaults[vaultUUID], args[2])
+      return "", nil
+    }
+
+    documents, ok := vaults[vaultUUID]
+    if !ok {
+      return "", fmt.Errorf("unexpected op call %v", args)
+    }
+    return testOpFuncWithStore(documents)(ctx, stdin, args)
+  }
+}
+
+func TestContextMigrateVault(t *testing.T) {
+  documents := map[string]string{
+    "git:https://github.com": "protocol=https\nhost=github.com\nusername=git-username\npassword=git-password\n",
+    "npm":                    "_authToken=npm-token\n",
+  }
+  vaults := map[string]map[string]string{
+    "vault-uuid": {},
+    "other-uuid": {"npm": "_authToken=old-token\n"},
+  }
+  for title, content := range documents {
+    vaults["vault-uuid"][title] = content
+  }
+
+  ks := newSignedInKeystore()
+  ctx := util.NewContext(testOpFuncWithVaults(vaults), ks, newTestStdin(""))
+
+  _, err := ctx.MigrateVault("missing", false, false)
+  require.NotNil(t, err)
+  _, err = ctx.MigrateVault("vault", false, false)
+  require.NotNil(t, err)
+
+  count, err := ctx.MigrateVault("other", false, false)
+  require.Nil(t, err)
+  require.Equal(t, 2, count)
+  require.Equal(t, documents, vaults["other-uuid"])
+  require.Equal(t, documents, vaults["vault-uuid"])
+
+  vaultName, err := ctx.GetVaultName()
+  require.Nil(t, err)
+  require.Equal(t, "other", vaultName)
+  vaultUUID, err := ks.Get("vault.uuid")
+  require.Nil(t, err)
+  require.Equal(t, "other-uuid", vaultUUID)
+
+  count, err = ctx.MigrateVault("new", true, true)
+  require.Nil(t, err)
+  require.Equal(t, 2, count)
+  require.Equal(t, documents, vaults["new-uuid"])
+  require.Equal(t, map[string]string{}, vaults["other-uuid"])
+}
+
+func TestContextMigrateVaultWithDuplicateTitles(t *testing.T) {
+  sim := optest.NewSimulator()
+  sim.SetDocument(optest.DefaultAccount, "credential-1password", "npm", "_authToken=npm-token\n")
+  // the os keystores return empty values for missing keys
+  ks := keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  "",
+    "session-token.value": "",
+    "vault.name":          "",
+    "vault.uuid":          "",
+  })
+  ctx := util.NewContext(sim.Op, ks, newTestStdin(""))
+  _, err := ctx.Export()
+  require.Nil(t, err)
+
+  // e.g. a document created by other means with a title already in use
+  sessionToken, _ := ks.Get("session-token.value")
+  vaultUUID, _ := ks.Get("vault.uuid")
+  query := op.Query{Context: op.Context{SessionToken: sessionToken, VaultUUID: vaultUUID}, Key: "npm"}
+  duplicate, err := op.CreateDocument(context.Background(), sim.Op, op.DocumentUpsert{Query: query, Title: "npm", FileName: "npm-credentials", Content: "_authToken=other-token\n"})
+  require.Nil(t, err)
+
+  _, err = ctx.MigrateVault("other", true, true)
+  require.NotNil(t, err)
+  require.Equal(t, "unable to migrate to vault 'other', vault 'credential-1password' has more than one document titled npm", err.Error())
+  vaultName, err := ctx.GetVaultName()
+  require.Nil(t, err)
+  require.Equal(t, "credential-1password", vaultName)
+  require.Equal(t, 2, len(sim.Accounts[optest.DefaultAccount].Vaults))
+
+  require.Nil(t, op.DeleteDocument(context.Background(), sim.Op, op.Query{Context: query.Context, Key: duplicate.UUID}))
+  count, err := ctx.MigrateVault("other", true, true)
+  require.Nil(t, err)
+  require.Equal(t, 1, count)
+  content, ok := sim.Document(optest.DefaultAccount, "other", "npm")
+  require.True(t, ok)
+  require.Equal(t, "_authToken=npm-token\n", content)
+  documents, err := op.ListDocuments(context.Background(), sim.Op, query.Context)
+  require.Nil(t, err)
+  require.Equal(t, 0, len(documents))
+}
+
+func TestContextSetVaultName(t *testing.T) {
+  vaults := map[string]map[string]string{"vault-name-uuid": {}}
+  ks := newSignedInKeystore()
+  ctx := util.NewContext(testOpFuncWithVaults(vaults), ks, newTestStdin(""))
+
+  err := ctx.SetVaultName("missing", false)
+  require.True(t, errors.Is(err, op.ErrVaultNotFound))
+  vaultName, _ := ks.Get("vault.name")
+  require.Equal(t, "vault-name", vaultName)
+
+  require.Nil(t, ctx.SetVaultName("created", true))
+  require.Contains(t, vaults, "created-uuid")
+  vaultUUID, _ := ks.Get("vault.uuid")
+  require.Equal(t, "created-uuid", vaultUUID)
+
+  // the default vault is created on first use
+  vaults = map[string]map[string]string{}
+  ks = keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "session-token",
+    "vault.name":          "",
+    "vault.uuid":          "",
+  })
+  _, err = util.NewContext(testOpFuncWithVaults(vaults), ks, newTestStdin("")).Export()
+  require.Nil(t, err)
+  require.Contains(t, vaults, "credential-1password-uuid")
+
+  // other vaults are not
+  require.Nil(t, ks.Set("vault.name", "other"))
+  require.Nil(t, ks.Set("vault.uuid", ""))
+  _, err = util.NewContext(testOpFuncWithVaults(vaults), ks, newTestStdin("")).Export()
+  require.True(t, errors.Is(err, op.ErrVaultNotFound))
+  require.NotContains(t, vaults, "other-uuid")
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestContextGetNetrc(t *testing.T) {
+  ctx := util.NewContext(testOpFuncWithDocuments(map[string]string{
+    "git:https://github.com":      "protocol=https\nhost=github.com\nusername=my-username\npassword=my-password\n",
+    "git:https://gitlab.com:8443": "protocol=https\nhost=gitlab.com:8443\nusername=other-username\npassword=pass=word\n",
+    "git:https://example.com":     "protocol=https\nhost=example.com\nusername=my-username\npassword=has spaces\n",
+    "docker:https://ghcr.io":      "{\"ServerURL\":\"https://ghcr.io\",\"Username\":\"my-username\",\"Secret\":\"my-secret\"}",
+    "npm":                         "_authToken=my-auth-token\n",
+  }), newSignedInKeystore(), newTestStdin(""))
+  ctx.Flags.Mode = string(util.NetrcMode)
+
+  netrc, err := ctx.GetNetrc()
+  require.Nil(t, err)
+  require.Equal(t, "machine github.com login my-username password my-password\nmachine gitlab.com login other-username password pass=word\n", netrc)
+
+  // no git credentials
+  ctx = util.NewContext(testOpFuncWithDocuments(map[string]string{}), newSignedInKeystore(), newTestStdin(""))
+  netrc, err = ctx.GetNetrc()
+  require.Nil(t, err)
+  require.Equal(t, "", netrc)
+
+  // list failure
+  ctx = util.NewContext(testOpFuncWithErr("test-error-message"), newSignedInKeystore(), newTestStdin(""))
+  _, err = ctx.GetNetrc()
+  require.NotNil(t, err)
+  require.Equal(t, "test-error-message", err.Error())
+}
+
+func TestServeFIFO(t *testing.T) {
+  path := filepath.Join(t.TempDir(), ".netrc")
+  content := "machine github.com login my-username password my-password\n"
+
+  errs := make(chan error, 1)
+  go func() { errs <- util.ServeFIFO(path, content) }()
+
+  require.Eventually(t, func() bool {
+    info, err := os.Lstat(path)
+    return err == nil && info.Mode()&os.ModeNamedPipe != 0
+  }, time.Second, 10*time.Millisecond)
+
+  data, err := os.ReadFile(path)
+  require.Nil(t, err)
+  require.Equal(t, content, string(data))
+  require.Nil(t, <-errs)
+
+  _, err = os.Lstat(path)
+  require.True(t, os.IsNotExist(err))
+
+  // existing files are never replaced
+  require.Nil(t, os.WriteFile(path, []byte("existing"), 0600))
+  err = util.ServeFIFO(path, content)
+  require.NotNil(t, err)
+  data, _ = os.ReadFile(path)
+  require.Equal(t, "existing", string(data))
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+  "testing"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestParseReference(t *testing.T) {
+  ref, err := util.ParseReference("git:https://github.com")
+  require.Nil(t, err)
+  require.Equal(t, util.Reference{Mode: util.GitMode, Key: "https://github.com"}, ref)
+  require.Equal(t, "git:https://github.com", ref.String())
+
+  ref, err = util.ParseReference("git:https://github.com#username")
+  require.Nil(t, err)
+  require.Equal(t, util.Reference{Mode: util.GitMode, Key: "https://github.com", Field: "username"}, ref)
+  require.Equal(t, "git:https://github.com#username", ref.String())
+
+  ref, err = util.ParseReference("npm")
+  require.Nil(t, err)
+  require.Equal(t, util.Reference{Mode: util.Mode("npm")}, ref)
+
+  ref, err = util.ParseReference("npm#_authToken")
+  require.Nil(t, err)
+  require.Equal(t, util.Reference{Mode: util.Mode("npm"), Field: "_authToken"}, ref)
+
+  _, err = util.ParseReference("git")
+  require.NotNil(t, err)
+
+  _, err = util.ParseReference("npm:registry")
+  require.NotNil(t, err)
+
+  _, err = util.ParseReference("netrc:github.com")
+  require.NotNil(t, err)
+
+  _, err = util.ParseReference("n pm")
+  require.NotNil(t, err)
+}
+
+func TestContextResolve(t *testing.T) {
+  documents := map[string]string{
+    "git:https://github.com":       "protocol=https\nhost=github.com\nusername=my-username\npassword=my-password\n",
+    "docker:https://ghcr.io":       "{\"ServerURL\":\"ghcr.io\",\"Username\":\"my-username\",\"Secret\":\"my-secret\"}\n",
+    "goauth:https://goproxy.io":    "url=https://goproxy.io\ntoken=my-token\n",
+    "maven:nexus":                  "id=nexus\nusername=my-username\npassword=nexus-password\n",
+    "npm":                          "_authToken=my-auth-token\nalways-auth=true\n",
+  }
+  ctx := util.NewContext(testOpFuncWithDocuments(documents), newSignedInKeystore(), newTestStdin(""))
+
+  cases := map[string]string{
+    "git:https://github.com":              "my-password",
+    "git:https://github.com#username":     "my-username",
+    "git:https://user@github.com":         "my-password",
+    "docker:ghcr.io":                      "my-secret",
+    "docker:https://ghcr.io/v2/#username": "my-username",
+    "goauth:https://goproxy.io/":          "my-token",
+    "maven:nexus":                         "nexus-password",
+    "npm":                                 "_authToken=my-auth-token\nalways-auth=true",
+    "npm#_authToken":                      "my-auth-token",
+  }
+
+  for reference, expValue := range cases {
+    ref, err := util.ParseReference(reference)
+    require.Nil(t, err, reference)
+
+    value, err := ctx.Resolve(ref)
+    require.Nil(t, err, reference)
+    require.Equal(t, expValue, value, reference)
+  }
+
+  ref, err := util.ParseReference("git:https://github.com#token")
+  require.Nil(t, err)
+  _, err = ctx.Resolve(ref)
+  require.NotNil(t, err)
+  require.Equal(t, "unable to resolve git:https://github.com#token: no field token", err.Error())
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+  "context"
+  "crypto/ed25519"
+  "crypto/rand"
+  "fmt"
+  "net"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/util"
+  "golang.org/x/crypto/ssh/agent"
+)
+
+func testOpFuncWithAccount(calls *[]string) func(ctx context.Context, stdin string, args []string) (string, error) {
+  return func(ctx context.Context, stdin string, args []string) (string, error) {
+    *calls = append(*calls, strings.Join(args, " "))
+    if args[len(args)-1] == "expired-token" {
+      return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 Invalid session token")
+    }
+    switch strings.Join(args, " ") {
+    case "get account --session session-token":
+      return `{"uuid":"account-uuid","name":"My Account","domain":"my.1password.com"}`, nil
+    case "signout --session session-token":
+      return "", nil
+    case "get account --session offline-token":
+      return "", fmt.Errorf("[ERROR] 2021/04/29 14:42:46 dial tcp: lookup my.1password.com: no such host")
+    }
+    return "", fmt.Errorf("unexpected op call %v", args)
+  }
+}
+
+func TestContextStatus(t *testing.T) {
+  calls := []string{}
+  ctx := util.NewContext(testOpFuncWithAccount(&calls), newSignedInKeystore(), newTestStdin(""))
+  status, err := ctx.Status()
+  require.Nil(t, err)
+  require.True(t, status.SignedIn)
+  require.Equal(t, "My Account (my.1password.com)", status.Account)
+  require.Equal(t, "vault-name", status.Vault)
+  require.Equal(t, "vault-uuid", status.VaultUUID)
+  require.Equal(t, 30*time.Minute, status.ExpiresAt.Sub(status.SignedInAt))
+  require.Contains(t, status.String(), "account: My Account (my.1password.com)\n")
+
+  // expired sessions are reported without calling op or signing in
+  calls = []string{}
+  ctx = util.NewContext(testOpFuncWithAccount(&calls), keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Add(-time.Hour).Format(time.UnixDate),
+    "session-token.value": "session-token",
+    "vault.name":          "",
+  }), newTestStdin(""))
+  status, err = ctx.Status()
+  require.Nil(t, err)
+  require.False(t, status.SignedIn)
+  require.Equal(t, "credential-1password", status.Vault)
+  require.Equal(t, 0, len(calls))
+  require.Equal(t, "signed in: no\nvault: credential-1password\n", status.String())
+
+  // rejected sessions
+  ctx = util.NewContext(testOpFuncWithAccount(&calls), keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "expired-token",
+    "vault.name":          "vault-name",
+  }), newTestStdin(""))
+  status, err = ctx.Status()
+  require.Nil(t, err)
+  require.False(t, status.SignedIn)
+
+  // other op errors aren't mistaken for being signed in
+  ctx = util.NewContext(testOpFuncWithAccount(&calls), keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "offline-token",
+    "vault.name":          "vault-name",
+  }), newTestStdin(""))
+  status, err = ctx.Status()
+  require.NotNil(t, err)
+  require.Contains(t, err.Error(), "no such host")
+  require.False(t, status.SignedIn)
+}
+
+func TestContextLogout(t *testing.T) {
+  calls := []string{}
+  ks := newSignedInKeystore()
+  ctx := util.NewContext(testOpFuncWithAccount(&calls), ks, newTestStdin(""))
+  notified, err := ctx.Logout()
+  require.Nil(t, err)
+  require.False(t, notified)
+  require.Equal(t, []string{"signout --session session-token"}, calls)
+
+  for _, key := range []string{"session-token.date", "session-token.value", "vault.uuid"} {
+    _, err := ks.Get(key)
+    require.NotNil(t, err)
+  }
+  vaultName, err := ks.Get("vault.name")
+  require.Nil(t, err)
+  require.Equal(t, "vault-name", vaultName)
+
+  // already expired sessions are still removed
+  calls = []string{}
+  ks = keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": "expired-token",
+    "vault.name":          "vault-name",
+  })
+  _, err = util.NewContext(testOpFuncWithAccount(&calls), ks, newTestStdin("")).Logout()
+  require.Nil(t, err)
+  _, err = ks.Get("session-token.value")
+  require.NotNil(t, err)
+}
+
+func TestContextLogoutNotifiesSSHAgent(t *testing.T) {
+  _, privateKey, err := ed25519.GenerateKey(rand.Reader)
+  require.Nil(t, err)
+  keyring := agent.NewKeyring()
+  require.Nil(t, keyring.Add(agent.AddedKey{PrivateKey: privateKey}))
+
+  socketPath := filepath.Join(t.TempDir(), "agent.sock")
+  listener, err := net.Listen("unix", socketPath)
+  require.Nil(t, err)
+  defer listener.Close()
+  go func() {
+    for {
+      conn, err := listener.Accept()
+      if err != nil {
+        return
+      }
+      go func() {
+        defer conn.Close()
+        agent.ServeAgent(keyring, conn)
+      }()
+    }
+  }()
+
+  calls := []string{}
+  ks := newSignedInKeystore()
+  ctx := util.NewContext(testOpFuncWithAccount(&calls), ks, newTestStdin(""))
+  require.Nil(t, ctx.SetSSHAgentSocket(socketPath))
+
+  notified, err := ctx.Logout()
+  require.Nil(t, err)
+  require.True(t, notified)
+
+  listed, err := keyring.List()
+  require.Nil(t, err)
+  require.Equal(t, 0, len(listed))
+
+  // a stale socket is forgotten
+  listener.Close()
+  notified, err = ctx.Logout()
+  require.Nil(t, err)
+  require.False(t, notified)
+  _, err = ks.Get("ssh-agent.socket")
+  require.NotNil(t, err)
+}
+
+func TestContextLogoutSSHAgentOfAccount(t *testing.T) {
+  calls := []string{}
+  ks := newSignedInKeystore()
+  work := util.NewContext(testOpFuncWithAccount(&calls), ks, newTestStdin(""))
+  work.Flags.Account = "work"
+  require.Nil(t, work.SetSSHAgentSocket(filepath.Join(t.TempDir(), "agent.sock")))
+
+  // logging out of another account leaves the account's ssh agent alone
+  notified, err := util.NewContext(testOpFuncWithAccount(&calls), ks, newTestStdin("")).Logout()
+  require.Nil(t, err)
+  require.False(t, notified)
+  _, err = ks.Get("accounts.work.ssh-agent.socket")
+  require.Nil(t, err)
+  _, err = ks.Get("ssh-agent.socket")
+  require.NotNil(t, err)
+}
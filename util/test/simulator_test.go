@@ -0,0 +1,57 @@
+package test
+
+import (
+  "testing"
+
+  "github.com/spf13/cobra"
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op/optest"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+func TestContextWithSimulator(t *testing.T) {
+  sim := optest.NewSimulator()
+  // the os keystores return empty values for missing keys
+  ks := keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  "",
+    "session-token.value": "",
+    "vault.name":          "",
+    "vault.uuid":          "",
+  })
+
+  // the default vault is created on first use
+  ctx := util.NewContext(sim.Op, ks, newTestStdin("protocol=https\nhost=github.com\nusername=username\npassword=password\n"))
+  ctx.Flags.Mode = string(util.GitMode)
+  ctx.SetCmd(&cobra.Command{Use: "store"})
+  require.Nil(t, ctx.ParseInput())
+  require.Nil(t, ctx.Store())
+
+  vaultName, err := ctx.GetVaultName()
+  require.Nil(t, err)
+  content, ok := sim.Document(optest.DefaultAccount, vaultName, "git:https://github.com")
+  require.True(t, ok)
+  require.Contains(t, content, "password=password")
+
+  // the session and vault are read back from the keystore
+  value, err := ks.Get("session-token.value")
+  require.Nil(t, err)
+  require.NotEqual(t, "", value)
+
+  ctx = util.NewContext(sim.Op, ks, newTestStdin(""))
+  document, err := ctx.GetCredential(util.Credential{Mode: util.GitMode, Input: "protocol=https\nhost=github.com\n"})
+  require.Nil(t, err)
+  require.Equal(t, content, document)
+
+  // an expired session is signed into again
+  expired, err := ks.Get("session-token.value")
+  require.Nil(t, err)
+  sim.ExpireSessions()
+  ctx = util.NewContext(sim.Op, ks, newTestStdin(""))
+  document, err = ctx.GetCredential(util.Credential{Mode: util.GitMode, Input: "protocol=https\nhost=github.com\n"})
+  require.Nil(t, err)
+  require.Equal(t, content, document)
+  value, err = ks.Get("session-token.value")
+  require.Nil(t, err)
+  require.NotEqual(t, expired, value)
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/stretchr/testify/require"
+  "github.com/tlowerison/credential-1password/keystore"
+  "github.com/tlowerison/credential-1password/op"
+  "github.com/tlowerison/credential-1password/op/optest"
+  "github.com/tlowerison/credential-1password/util"
+)
+
+// installFakeOp puts optest's fake op binary first in PATH, with a vault
+// named Work besides the default one, and returns a keystore which is
+// signed into it.
+func installFakeOp(tb testing.TB) keystore.Keystore {
+  if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
+    tb.Skip("the fake op binary requires flock")
+  }
+
+  dir := tb.TempDir()
+  _, err := optest.Install(dir)
+  require.Nil(tb, err)
+
+  ctx := context.Background()
+  sim := optest.NewSimulator()
+  sessionToken, err := op.Signin(ctx, sim.Op, "")
+  require.Nil(tb, err)
+  vault, err := op.GetVault(ctx, sim.Op, op.Query{Context: op.Context{SessionToken: sessionToken}, Key: optest.DefaultVault})
+  require.Nil(tb, err)
+  _, err = op.CreateVault(ctx, sim.Op, op.CreateVaultMutation{SessionToken: sessionToken, Title: "Work", Description: "work"})
+  require.Nil(tb, err)
+  statePath := filepath.Join(dir, "state.json")
+  require.Nil(tb, sim.Save(statePath))
+
+  path := os.Getenv("PATH")
+  os.Setenv("PATH", dir + string(os.PathListSeparator) + path)
+  os.Setenv(optest.StateEnv, statePath)
+  tb.Cleanup(func() {
+    os.Setenv("PATH", path)
+    os.Setenv(optest.StateEnv, "")
+  })
+
+  return keystore.NewMockKeystore(nil, map[string]string{
+    "session-token.date":  time.Now().Format(time.UnixDate),
+    "session-token.value": sessionToken,
+    "vault.name":          vault.Name,
+    "vault.uuid":          vault.UUID,
+  })
+}
+
+// opSpawns wraps op.Op, which launches op once per call, and returns
+// a function which returns and clears the commands op was called with.
+func opSpawns() (op.OpFunc, func() []string) {
+  mu := sync.Mutex{}
+  calls := []string{}
+  opFunc := func(ctx context.Context, stdin string, args []string) (string, error) {
+    mu.Lock()
+    calls = append(calls, fmt.Sprintf("%s %s", args[0], args[1]))
+    mu.Unlock()
+    return op.Op(ctx, stdin, args)
+  }
+  return opFunc, func() []string {
+    mu.Lock()
+    defer mu.Unlock()
+    spawned := calls
+    calls = []string{}
+    return spawned
+  }
+}
+
+func getWithOp(tb testing.TB, opFunc op.OpFunc, ks keystore.Keystore, input string) (string, error) {
+  ctx := util.NewContext(opFunc, ks, newTestStdin(""))
+  return ctx.GetCredential(util.Credential{Mode: util.GitMode, Input: input})
+}
+
+func storeWithOp(tb testing.TB, opFunc op.OpFunc, ks keystore.Keystore, input string) {
+  ctx := util.NewContext(opFunc, ks, newTestStdin(input))
+  ctx.Flags.Mode = string(util.GitMode)
+  ctx.SetCmd(&cobra.Command{Use: "store"})
+  require.Nil(tb, ctx.ParseInput())
+  require.Nil(tb, ctx.Store())
+}
+
+func TestOpSpawns(t *testing.T) {
+  ks := installFakeOp(t)
+  opFunc, calls := opSpawns()
+  input := "protocol=https\nhost=github.com\nusername=username\npassword=password\n"
+
+  // git looks a credential up before storing it, so storing a
+  // new credential creates it without checking that it exists
+  _, err := getWithOp(t, opFunc, ks, "protocol=https\nhost=github.com\n")
+  require.True(t, errors.Is(err, op.ErrNotFound))
+  require.Equal(t, []string{"get document"}, calls())
+  storeWithOp(t, opFunc, ks, input)
+  require.Equal(t, []string{"create document"}, calls())
+
+  // updating an existing document launches op once
+  storeWithOp(t, opFunc, ks, input)
+  require.Equal(t, []string{"edit document"}, calls())
+
+  document, err := getWithOp(t, opFunc, ks, "protocol=https\nhost=github.com\n")
+  require.Nil(t, err)
+  require.Contains(t, document, "password=password")
+  require.Equal(t, []string{"get document"}, calls())
+
+  // erasing a credential also records it as missing
+  ctx := util.NewContext(opFunc, ks, newTestStdin("protocol=https\nhost=github.com\n"))
+  ctx.Flags.Mode = string(util.GitMode)
+  ctx.SetCmd(&cobra.Command{Use: "erase"})
+  require.Nil(t, ctx.ParseInput())
+  require.Nil(t, ctx.DeleteDocument())
+  require.Equal(t, []string{"delete document"}, calls())
+  storeWithOp(t, opFunc, ks, input)
+  require.Equal(t, []string{"create document"}, calls())
+
+  // without a lookup, editing doubles as the existence check
+  storeWithOp(t, opFunc, ks, "protocol=https\nhost=gitlab.com\nusername=username\npassword=password\n")
+  require.Equal(t, []string{"edit document", "create document"}, calls())
+
+  // vaults are only looked up once per process
+  ctx = util.NewContext(opFunc, ks, newTestStdin(""))
+  for i := 0; i < 3; i++ {
+    _, err = ctx.GetCredential(util.Credential{Mode: util.GitMode, Input: "protocol=https\nhost=github.com\n", Vault: "Work"})
+    require.True(t, errors.Is(err, op.ErrNotFound))
+  }
+  require.Equal(t, []string{"get vault", "get document", "get document", "get document"}, calls())
+}
+
+// BenchmarkStore reports how many times op is launched to store a
+// credential after looking it up, as git does on each successful
+// fill, for both new and existing credentials.
+func BenchmarkStore(b *testing.B) {
+  ks := installFakeOp(b)
+  opFunc, calls := opSpawns()
+
+  hosts := 0
+  b.Run("new", func(b *testing.B) {
+    spawns := 0
+    for i := 0; i < b.N; i++ {
+      hosts++
+      host := fmt.Sprintf("host=%d.example.com\n", hosts)
+      getWithOp(b, opFunc, ks, "protocol=https\n" + host)
+      calls()
+      storeWithOp(b, opFunc, ks, "protocol=https\n" + host + "username=username\npassword=password\n")
+      spawns += len(calls())
+    }
+    b.ReportMetric(float64(spawns) / float64(b.N), "spawns/op")
+  })
+
+  b.Run("existing", func(b *testing.B) {
+    input := "protocol=https\nhost=github.com\nusername=username\npassword=password\n"
+    storeWithOp(b, opFunc, ks, input)
+    calls()
+
+    spawns := 0
+    for i := 0; i < b.N; i++ {
+      storeWithOp(b, opFunc, ks, input)
+      spawns += len(calls())
+    }
+    b.ReportMetric(float64(spawns) / float64(b.N), "spawns/op")
+  })
+}